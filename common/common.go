@@ -2,35 +2,151 @@ package common
 
 // Directory and File Configuration
 const (
-	VCS_DIR          = "./vcs"
+	VCS_DIR_NAME     = "vcs"
 	CONFIG_FILE_NAME = "config.txt"
 	INDEX_FILE_NAME  = "index.txt"
+	HEAD_FILE_NAME   = "HEAD"
+	OBJECTS_DIR_NAME = "objects"
+	PACK_DIR_NAME    = "pack"
+	PACK_EXT         = ".pack"
+	PACK_INDEX_EXT   = ".idx"
+	LOGS_DIR_NAME    = "logs"
+	REFS_DIR_NAME    = "refs"
+	HEADS_DIR        = REFS_DIR_NAME + "/heads"
+	REMOTES_DIR      = REFS_DIR_NAME + "/remotes"
+	STASH_REF        = REFS_DIR_NAME + "/stash"
+	DEFAULT_BRANCH   = "master"
+
+	MERGE_HEAD_FILE_NAME = "MERGE_HEAD"
+	MERGE_MSG_FILE_NAME  = "MERGE_MSG"
+	CONFLICTS_FILE_NAME  = "MERGE_CONFLICTS"
+
+	CHERRY_PICK_HEAD_FILE_NAME = "CHERRY_PICK_HEAD"
+	REVERT_HEAD_FILE_NAME      = "REVERT_HEAD"
+	REBASE_DIR_NAME            = "rebase-merge"
+)
+
+// Configuration keys
+const (
+	USER_NAME_KEY   = "user.name"
+	BARE_KEY        = "core.bare"
+	COMPRESSION_KEY = "core.compression"
+	HASH_KEY        = "core.hash"
+	REMOTE_KEY      = "remote.%s.url"
 )
 
+const DEFAULT_REMOTE = "origin"
+
+// Repository location overrides
+const (
+	REPO_DIR_ENV  = "SVCS_DIR"
+	REPO_DIR_FLAG = "--repo-dir"
+)
+
+// SSH_COMMAND_ENV names the command used to reach ssh:// remotes, "ssh" by default
+const SSH_COMMAND_ENV = "SVCS_SSH"
+
+// EDITOR_ENV names the editor opened for commit messages and rebase plans, "vi" by default
+const EDITOR_ENV = "EDITOR"
+
+const INIT = "init"
+const BARE_FLAG = "--bare"
+const HASH_FLAG = "--hash"
 const CONFIG = "config"
+const CLONE = "clone"
 const ADD = "add"
 const LOG = "log"
 const COMMIT = "commit"
 const CHECKOUT = "checkout"
+const REMOTE = "remote"
+const FETCH = "fetch"
+const PUSH = "push"
+const PULL = "pull"
+const FORCE_FLAG = "--force"
+const SERVE = "serve"
+const GC = "gc"
+const PRUNE = "prune"
+const EXPIRE_FLAG = "--expire"
+const FSCK = "fsck"
+const REFLOG = "reflog"
+const STASH = "stash"
+const CHERRY_PICK = "cherry-pick"
+const REVERT = "revert"
+const REBASE = "rebase"
+const CONTINUE_FLAG = "--continue"
+const SKIP_FLAG = "--skip"
+const ABORT_FLAG = "--abort"
+const INTERACTIVE_FLAG = "-i"
+const BLAME = "blame"
+const BISECT = "bisect"
+const LINE_RANGE_FLAG = "-L"
+const PORCELAIN_FLAG = "--porcelain"
+const MESSAGE_FLAG = "-m"
+const FILE_FLAG = "-F"
+const AMEND_FLAG = "--amend"
+const ALL_FLAG = "-a"
+const PATCH_FLAG = "-p"
+const ADD_ALL_FLAG = "-A"
+const UNREACHABLE_FLAG = "--unreachable"
+const HTTP_FLAG = "--http"
+const STDIO_FLAG = "--stdio"
+const ALLOW_PUSH_FLAG = "--allow-push"
 const HELP = "--help"
 
 const CommandsText = "These are SVCS commands:"
 const IS_NOT_COMMAND = "'%s' is not a SVCS command.\n"
+const NOT_A_REPOSITORY = "Not a SVCS repository (or any of the parent directories): %s\n"
+const OPTION_REQUIRES_VALUE = "Option '%s' requires a value.\n"
+const NOT_A_WORK_TREE = "This operation must be run in a work tree."
 
 const HELP_MESSAGE = `
 These are SVCS commands:
-config     Get and set a username.
-add        Add a file to the index.
-log        Show commit logs.
-commit     Save changes.
-checkout   Restore a file.
+init         Create an empty repository, optionally --bare or with --hash blake3.
+clone        Copy a repository into a new directory.
+config       Get and set a username or another setting.
+add          Add files, directories or glob patterns to the index, -A everything, -p chosen hunks.
+log          Show commit logs.
+commit       Save changes.
+checkout     Switch to a branch or commit.
+reflog       Show where HEAD or a branch has pointed.
+stash        Shelve uncommitted changes and restore them later.
+cherry-pick  Apply the changes of a commit on top of HEAD.
+revert       Undo the changes of a commit with a new commit.
+rebase       Replay the commits of the current branch on top of another, -i edits the plan first.
+blame        Show the commit that last changed each line of a file.
+bisect       Binary search history for the commit that introduced a bug.
+remote       Add and list remote repositories.
+fetch        Download objects and refs from a remote.
+push         Update a remote branch with local commits.
+pull         Fetch from a remote and merge into the current branch.
+serve        Share the repository over HTTP or stdin/stdout.
+gc           Pack reachable objects and prune unreachable ones.
+prune        Delete unreachable objects older than --expire.
+fsck         Verify the objects, refs and index of the repository.
 `
 
 var Commands = map[string]string{
-	CONFIG:   "Get and set a username.",
-	ADD:      "Add a file to the index.",
-	LOG:      "Show commit logs.",
-	COMMIT:   "Save changes.",
-	CHECKOUT: "Restore a file.",
-	HELP:     HELP_MESSAGE,
+	INIT:        "Create an empty repository, optionally --bare or with --hash blake3.",
+	CLONE:       "Copy a repository into a new directory.",
+	CONFIG:      "Get and set a username or another setting.",
+	ADD:         "Add files, directories or glob patterns to the index, -A everything, -p chosen hunks.",
+	LOG:         "Show commit logs.",
+	COMMIT:      "Save changes.",
+	CHECKOUT:    "Switch to a branch or commit.",
+	REFLOG:      "Show where HEAD or a branch has pointed.",
+	STASH:       "Shelve uncommitted changes and restore them later.",
+	CHERRY_PICK: "Apply the changes of a commit on top of HEAD.",
+	REVERT:      "Undo the changes of a commit with a new commit.",
+	REBASE:      "Replay the commits of the current branch on top of another, -i edits the plan first.",
+	BLAME:       "Show the commit that last changed each line of a file.",
+	BISECT:      "Binary search history for the commit that introduced a bug.",
+	REMOTE:      "Add and list remote repositories.",
+	FETCH:       "Download objects and refs from a remote.",
+	PUSH:        "Update a remote branch with local commits.",
+	PULL:        "Fetch from a remote and merge into the current branch.",
+	SERVE:       "Share the repository over HTTP or stdin/stdout.",
+	GC:          "Pack reachable objects and prune unreachable ones.",
+	PRUNE:       "Delete unreachable objects older than --expire.",
+	FSCK:        "Verify the objects, refs and index of the repository.",
+	HELP:        HELP_MESSAGE,
 }
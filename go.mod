@@ -1,3 +1,7 @@
 module version_control_go
 
 go 1.19
+
+require lukechampine.com/blake3 v1.1.7
+
+require github.com/klauspost/cpuid/v2 v2.0.9 // indirect
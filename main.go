@@ -5,6 +5,5 @@ import (
 )
 
 func main() {
-	utils.CreateVcsDir()
 	utils.Interaction()
 }
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"version_control_go/common"
+)
+
+const stagedChange = "%s %s\n"
+const stagedSummary = "Staged %d new, %d modified and %d deleted files.\n"
+const nothingToStage = "Nothing to stage, the index is up to date."
+
+// Marks of the staging summary, in the style of 'stash show'
+const (
+	stagedNew      = "A"
+	stagedModified = "M"
+	stagedDeleted  = "D"
+)
+
+// addPaths stages every file the pathspec arguments select, such as files, directories with everything below
+// them, "." or 'src/**/*.go'; tracked files gone from the work tree are unstaged. -A alone stages everything
+func addPaths(args []string) {
+	var specs []string
+	all := false
+	for _, arg := range args {
+		if arg == common.ADD_ALL_FLAG {
+			all = true
+		} else {
+			specs = append(specs, arg)
+		}
+	}
+	if len(specs) == 0 {
+		if !all {
+			fmt.Println(addFileToIndex)
+			return
+		}
+		specs = []string{workTreeDir}
+	}
+
+	// A single file keeps the short answer add always gave
+	if len(specs) == 1 && !all && !strings.ContainsAny(specs[0], globMeta) && !strings.HasPrefix(specs[0], ":") {
+		if info, err := os.Stat(specs[0]); err == nil && info.Mode().IsRegular() {
+			addFile(specs[0])
+			return
+		}
+	}
+
+	entries := fillLegacyIndexEntries(readIndex())
+	indexHashes := entryHashes(entries)
+	paths, ok := matchAddPaths(specs, indexHashes)
+	if !ok {
+		return
+	}
+
+	added, modified, deleted := 0, 0, 0
+	for _, trackedPath := range paths {
+		data, err := os.ReadFile(workTreeFile(trackedPath))
+		if errors.Is(err, os.ErrNotExist) {
+			entries = unstageEntry(entries, trackedPath)
+			fmt.Printf(stagedChange, stagedDeleted, trackedPath)
+			deleted++
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		hash := writeBlob(data)
+		indexHash, tracked := indexHashes[trackedPath]
+		switch {
+		case !tracked:
+			fmt.Printf(stagedChange, stagedNew, trackedPath)
+			added++
+		case indexHash != hash:
+			fmt.Printf(stagedChange, stagedModified, trackedPath)
+			modified++
+		default:
+			continue
+		}
+		entries = stageEntry(entries, trackedPath, hash)
+	}
+	writeIndex(entries)
+	resolveConflicts(paths...)
+
+	if added+modified+deleted == 0 {
+		fmt.Println(nothingToStage)
+		return
+	}
+	fmt.Printf(stagedSummary, added, modified, deleted)
+}
+
+// addFile stores the content of one file as a blob and points its index entry at it
+func addFile(file string) {
+	// Track the file relative to the work tree so it resolves from any subdirectory
+	trackedPath, err := workTreePath(file)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	hash, err := writeBlobFromFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeIndex(stageEntry(readIndex(), trackedPath, hash))
+	resolveConflicts(trackedPath)
+
+	fmt.Printf(fileIsTracked, file)
+}
+
+// matchAddPaths returns the work tree files and tracked paths the arguments select, complaining about an
+// argument that selects nothing
+func matchAddPaths(args []string, indexHashes map[string]string) ([]string, bool) {
+	spec, err := parsePathspec(args)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+
+	candidates := workTreeFiles()
+	for trackedPath := range indexHashes {
+		if _, err := os.Stat(workTreeFile(trackedPath)); errors.Is(err, os.ErrNotExist) {
+			candidates = append(candidates, trackedPath)
+		}
+	}
+	if arg, ok := spec.unmatched(candidates); ok {
+		fmt.Printf(canNotFindFile, arg)
+		return nil, false
+	}
+	return spec.filter(candidates), true
+}
+
+// workTreeFiles lists the regular files of the work tree as index paths, leaving out the repository directory
+func workTreeFiles() []string {
+	var files []string
+	err := filepath.WalkDir(workTreeDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file == repoDir || entry.Name() == common.VCS_DIR_NAME {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(workTreeDir, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return files
+}
@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+const noChanges = "No changes."
+const notTracked = "'%s' is not tracked, use 'add %s' first.\n"
+const stageHunkPrompt = "(%d/%d) Stage this hunk [%s]? "
+const hunkHeader = "@@ -%d,%d +%d,%d @@\n"
+const splitInto = "Split into %d hunks.\n"
+const editedHunkDoesNotApply = "Your edited hunk does not apply."
+const stagedHunks = "Staged %d of %d hunks of %s\n"
+
+const stageHunkHelp = `y - stage this hunk
+n - do not stage this hunk
+s - split the current hunk into smaller hunks
+e - manually edit the current hunk
+q - quit; do not stage this hunk or any of the remaining ones
+? - print help
+`
+
+const editHunkHelp = `# ---
+# To remove '-' lines, make them ' ' lines (context).
+# To remove '+' lines, delete them.
+# Lines starting with # will be removed.
+# If the hunk does not apply, you will be asked about it again.
+# If all lines of the hunk are removed, the edit is dropped.
+`
+
+const addEditHunkFile = "ADD_EDIT.hunk"
+
+// hunkContext is how many unchanged lines are shown around a change
+const hunkContext = 3
+
+// patchHunk is a run of consecutive changes of a file shown as one hunk, first and last index into the changes
+// of the file; an edited hunk stages replacement in place of a[from:to] instead
+type patchHunk struct {
+	first, last int
+	chosen      bool
+	edited      bool
+	from, to    int
+	replacement []string
+}
+
+// patchFile is a tracked file whose index version a is being brought partly up to its work tree version b
+type patchFile struct {
+	path    string
+	a, b    []string
+	changes []diffHunk
+}
+
+// addPatch asks about every changed hunk of the tracked paths the pathspec arguments select, all of them by
+// default, and stages only the chosen hunks as new blobs
+func addPatch(args []string) {
+	entries := fillLegacyIndexEntries(readIndex())
+	indexHashes := entryHashes(entries)
+
+	spec, err := parsePathspec(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if arg, ok := spec.unmatched(sortedKeys(indexHashes)); ok {
+		fmt.Printf(notTracked, arg, arg)
+		return
+	}
+	paths := spec.filter(sortedKeys(indexHashes))
+
+	var files []patchFile
+	for _, path := range paths {
+		data, err := os.ReadFile(workTreeFile(path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		file := patchFile{path: path, a: splitLines(string(readBlobOrEmpty(indexHashes[path]))), b: splitLines(string(data))}
+		if file.changes = diffLines(file.a, file.b); len(file.changes) > 0 {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println(noChanges)
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, file := range files {
+		hunks, quit := selectHunks(file, reader)
+
+		chosen := 0
+		for _, hunk := range hunks {
+			if hunk.chosen {
+				chosen++
+			}
+		}
+		if chosen > 0 {
+			entries = stageEntry(entries, file.path, writeBlob([]byte(file.staged(hunks))))
+			writeIndex(entries)
+		}
+		fmt.Printf(stagedHunks, chosen, len(hunks), file.path)
+		if quit {
+			return
+		}
+	}
+}
+
+// selectHunks prompts for every hunk of the file in turn and reports whether the user quit
+func selectHunks(file patchFile, reader *bufio.Reader) ([]patchHunk, bool) {
+	hunks := file.initialHunks()
+	for i := 0; i < len(hunks); {
+		hunk := &hunks[i]
+		fmt.Print(file.format(*hunk))
+
+		options := "y,n,e,q,?"
+		if hunk.last > hunk.first {
+			options = "y,n,s,e,q,?"
+		}
+		fmt.Printf(stageHunkPrompt, i+1, len(hunks), options)
+		answer, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Fatal(err)
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" && errors.Is(err, io.EOF) {
+			fmt.Println()
+			return hunks, true
+		}
+
+		switch answer {
+		case "y":
+			hunk.chosen, hunk.edited = true, false
+			i++
+		case "n":
+			i++
+		case "s":
+			if hunk.last == hunk.first {
+				fmt.Print(stageHunkHelp)
+				continue
+			}
+			var split []patchHunk
+			for change := hunk.first; change <= hunk.last; change++ {
+				split = append(split, patchHunk{first: change, last: change})
+			}
+			fmt.Printf(splitInto, len(split))
+			hunks = append(hunks[:i], append(split, hunks[i+1:]...)...)
+		case "e":
+			if file.editHunk(hunk) {
+				i++
+			}
+		case "q":
+			return hunks, true
+		default:
+			fmt.Print(stageHunkHelp)
+		}
+	}
+	return hunks, false
+}
+
+// initialHunks groups changes whose context would overlap into one hunk
+func (file patchFile) initialHunks() []patchHunk {
+	var hunks []patchHunk
+	for i, change := range file.changes {
+		if len(hunks) > 0 {
+			previous := file.changes[i-1]
+			if change.aStart-(previous.aStart+previous.aLength) <= 2*hunkContext {
+				hunks[len(hunks)-1].last = i
+				continue
+			}
+		}
+		hunks = append(hunks, patchHunk{first: i, last: i})
+	}
+	return hunks
+}
+
+// region is the range of the index version a hunk shows, its changes with context that stops at the neighbouring
+// changes
+func (file patchFile) region(hunk patchHunk) (int, int) {
+	first, last := file.changes[hunk.first], file.changes[hunk.last]
+	from := first.aStart - hunkContext
+	if hunk.first > 0 {
+		previous := file.changes[hunk.first-1]
+		from = maxInt(from, previous.aStart+previous.aLength)
+	}
+	to := last.aStart + last.aLength + hunkContext
+	if hunk.last+1 < len(file.changes) {
+		to = minInt(to, file.changes[hunk.last+1].aStart)
+	}
+	return maxInt(from, 0), minInt(to, len(file.a))
+}
+
+// format prints a hunk the way a unified diff does
+func (file patchFile) format(hunk patchHunk) string {
+	from, to := file.region(hunk)
+	first, last := file.changes[hunk.first], file.changes[hunk.last]
+	bFrom := from + first.bStart - first.aStart
+	bTo := to + (last.bStart + last.bLength) - (last.aStart + last.aLength)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(hunkHeader, from+1, to-from, bFrom+1, bTo-bFrom))
+	position := from
+	for _, change := range file.changes[hunk.first : hunk.last+1] {
+		for ; position < change.aStart; position++ {
+			builder.WriteString(" " + withNewline(file.a[position]))
+		}
+		for _, line := range file.a[change.aStart : change.aStart+change.aLength] {
+			builder.WriteString("-" + withNewline(line))
+		}
+		for _, line := range file.b[change.bStart : change.bStart+change.bLength] {
+			builder.WriteString("+" + withNewline(line))
+		}
+		position = change.aStart + change.aLength
+	}
+	for ; position < to; position++ {
+		builder.WriteString(" " + withNewline(file.a[position]))
+	}
+	return builder.String()
+}
+
+// editHunk lets the user rewrite the hunk in $EDITOR and chooses it when the result still fits the index version
+func (file patchFile) editHunk(hunk *patchHunk) bool {
+	path := vcsPath(addEditHunkFile)
+	if err := os.WriteFile(path, []byte(file.format(*hunk)+editHunkHelp), 0644); err != nil {
+		log.Fatal(err)
+	}
+	defer removeFile(path)
+	if err := launchEditor(path); err != nil {
+		fmt.Printf(editorFailed, err)
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	from, to := file.region(*hunk)
+	replacement, ok := parseEditedHunk(string(data), file.a[from:to])
+	if !ok {
+		fmt.Println(editedHunkDoesNotApply)
+		return false
+	}
+	if replacement == nil {
+		return false
+	}
+
+	// Only the lines the edit changes are kept so the context stays free for the neighbouring hunks
+	for from < to && len(replacement) > 0 && replacement[0] == file.a[from] {
+		from, replacement = from+1, replacement[1:]
+	}
+	for from < to && len(replacement) > 0 && replacement[len(replacement)-1] == file.a[to-1] {
+		to, replacement = to-1, replacement[:len(replacement)-1]
+	}
+	hunk.chosen, hunk.edited = true, true
+	hunk.from, hunk.to, hunk.replacement = from, to, replacement
+	return true
+}
+
+// parseEditedHunk checks that the context and removed lines of an edited hunk are the original lines and returns
+// the lines that take their place; nil means every line was removed
+func parseEditedHunk(text string, original []string) ([]string, bool) {
+	var replacement []string
+	position := 0
+	empty := true
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if strings.HasPrefix(line, commentPrefix) || strings.HasPrefix(line, "@@") {
+			continue
+		}
+		empty = false
+
+		// Editors often drop the space of an empty context line
+		if line == "" {
+			line = " "
+		}
+		switch line[0] {
+		case ' ', '-':
+			if position == len(original) || strings.TrimSuffix(original[position], "\n") != line[1:] {
+				return nil, false
+			}
+			if line[0] == ' ' {
+				replacement = append(replacement, original[position])
+			}
+			position++
+		case '+':
+			replacement = append(replacement, line[1:]+"\n")
+		default:
+			return nil, false
+		}
+	}
+	if empty {
+		return nil, true
+	}
+	if position != len(original) {
+		return nil, false
+	}
+	return append([]string{}, replacement...), true
+}
+
+// staged puts the chosen hunks into the index version of the file
+func (file patchFile) staged(hunks []patchHunk) string {
+	var builder strings.Builder
+	position := 0
+	for _, hunk := range hunks {
+		if !hunk.chosen {
+			continue
+		}
+		if !hunk.edited {
+			for _, change := range file.changes[hunk.first : hunk.last+1] {
+				builder.WriteString(strings.Join(file.a[position:change.aStart], ""))
+				builder.WriteString(strings.Join(file.b[change.bStart:change.bStart+change.bLength], ""))
+				position = change.aStart + change.aLength
+			}
+			continue
+		}
+
+		// Two edits that changed the same context lines can't both be staged, the first one wins
+		if hunk.from < position {
+			continue
+		}
+		builder.WriteString(strings.Join(file.a[position:hunk.from], ""))
+		builder.WriteString(strings.Join(hunk.replacement, ""))
+		position = hunk.to
+	}
+	builder.WriteString(strings.Join(file.a[position:], ""))
+	return builder.String()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// withStdin runs fn reading input as its standard input
+func withStdin(t *testing.T, input string, fn func()) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() { os.Stdin = stdin }()
+	fn()
+}
+
+// twentyLines numbers the lines 1 to 20, replacing those in changed
+func twentyLines(changed map[int]string) string {
+	var builder strings.Builder
+	for i := 1; i <= 20; i++ {
+		line, ok := changed[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+// TestAddPatch changes lines 2 and 4, which share a hunk, and line 18 in a hunk of its own, then answers the
+// prompts of add -p
+func TestAddPatch(t *testing.T) {
+	workTree := twentyLines(map[int]string{2: "two", 4: "four", 18: "eighteen"})
+	tests := []struct {
+		name    string
+		answers string
+		// edit is what the editor leaves in the hunk file when it is opened
+		edit       string
+		wantStaged string
+		wantOutput string
+	}{
+		{name: "first hunk", answers: "y\nn\n", wantStaged: twentyLines(map[int]string{2: "two", 4: "four"}),
+			wantOutput: "Staged 1 of 2 hunks of f"},
+		{name: "second hunk", answers: "n\ny\n", wantStaged: twentyLines(map[int]string{18: "eighteen"})},
+		{name: "every hunk", answers: "y\ny\n", wantStaged: workTree},
+		{name: "split and mix", answers: "s\ny\nn\ny\n", wantStaged: twentyLines(map[int]string{2: "two", 18: "eighteen"}),
+			wantOutput: "Split into 2 hunks.\n"},
+		{name: "quit", answers: "q\n", wantStaged: twentyLines(nil), wantOutput: "Staged 0 of 2 hunks of f"},
+		{name: "end of input", answers: "y\n", wantStaged: twentyLines(map[int]string{2: "two", 4: "four"})},
+		{name: "edit", answers: "e\nn\n", edit: " 1\n-2\n+two\n 3\n-4\n+FOUR\n 5\n 6\n 7\n",
+			wantStaged: twentyLines(map[int]string{2: "two", 4: "FOUR"})},
+		{name: "edit dropping a change", answers: "e\ny\n", edit: "@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n",
+			wantStaged: twentyLines(map[int]string{4: "four", 18: "eighteen"})},
+		{name: "edit that does not apply", answers: "e\nn\nn\n", edit: " 1\n-x\n+two\n",
+			wantStaged: twentyLines(nil), wantOutput: editedHunkDoesNotApply},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			repo.commitFiles(t, "base", map[string]string{"f": twentyLines(nil)})
+			repo.writeFiles(t, map[string]string{"f": workTree})
+
+			t.Setenv(editorPlanEnv, "")
+			t.Setenv(editorMessageEnv, test.edit)
+			t.Setenv(common.EDITOR_ENV, os.Args[0]+" -test.run=^TestEditorHelper$ --")
+
+			var output string
+			withStdin(t, test.answers, func() { output = runCommand(t, common.ADD, common.PATCH_FLAG) })
+			if got := string(readBlobOrEmpty(entryHashes(readIndex())["f"])); got != test.wantStaged {
+				t.Errorf("staged f =\n%s\nwant\n%s", got, test.wantStaged)
+			}
+			if !strings.Contains(output, test.wantOutput) {
+				t.Errorf("add -p printed %q, want %q in it", output, test.wantOutput)
+			}
+			if got := repo.readWorkTreeFile(t, "f"); got != workTree {
+				t.Errorf("add -p changed the work tree to %q", got)
+			}
+		})
+	}
+}
+
+func TestAddPatchRefuses(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commitFiles(t, "base", map[string]string{"f": "1\n"})
+	repo.writeFiles(t, map[string]string{"untracked": "u\n"})
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "nothing changed", want: noChanges + "\n"},
+		{name: "untracked path", args: []string{"untracked"}, want: fmt.Sprintf(notTracked, "untracked", "untracked")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output string
+			withStdin(t, "", func() { output = runCommand(t, common.ADD, append([]string{common.PATCH_FLAG}, test.args...)...) })
+			if output != test.want {
+				t.Errorf("add -p %q printed %q, want %q", test.args, output, test.want)
+			}
+		})
+	}
+}
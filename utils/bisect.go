@@ -0,0 +1,348 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"version_control_go/common"
+)
+
+const bisectStartCommand = "start"
+const bisectGoodCommand = "good"
+const bisectBadCommand = "bad"
+const bisectSkipCommand = "skip"
+const bisectResetCommand = "reset"
+const bisectRunCommand = "run"
+
+const bisectUsage = "Usage: bisect start <bad> <good>... | good|bad|skip [<commit>] | reset | run <script> [<args>]"
+const bisectInProgress = "A bisect is already in progress, run 'bisect reset' first."
+const noBisectInProgress = "You need to start by 'bisect start <bad> <good>'."
+const waitingForBadAndGood = "Waiting for both good and bad commits."
+const badIsAncestorOfGood = "The bad commit %s is an ancestor of a good one, nothing to search.\n"
+const bisecting = "Bisecting: %d revisions left to test after this (roughly %d steps)\n[%s] %s\n"
+const firstBadCommit = "%s is the first bad commit\n"
+const onlySkippedLeft = "There are only 'skip'ped commits left to test.\nThe first bad commit could be any of:"
+const bisectResetTo = "Previous HEAD position was %s, back on %s.\n"
+const runningScript = "running %s\n"
+const bisectRunFailed = "bisect run failed: %v\n"
+const bisectRunCannotContinue = "bisect run cannot continue, '%s' exited with %d.\n"
+
+// Exit codes of a bisect run script: 0 is good, 125 skips, any other up to 127 is bad and anything higher aborts
+const (
+	bisectRunSkipCode    = 125
+	bisectRunLastBadCode = 127
+)
+
+// State files of a bisect, in the repository directory
+const (
+	bisectStartFile = "BISECT_START"
+	bisectBadFile   = "BISECT_BAD"
+	bisectGoodFile  = "BISECT_GOOD"
+	bisectSkipFile  = "BISECT_SKIP"
+)
+
+// bisectCase binary searches the commits between good and bad ones for the first bad commit, checking out the
+// midpoint of what is left after every verdict
+func bisectCase(consoleArgs []string) {
+	requireWorkTree()
+
+	if len(consoleArgs) < 3 {
+		fmt.Println(bisectUsage)
+		return
+	}
+	subcommand, args := consoleArgs[2], consoleArgs[3:]
+
+	if subcommand == bisectStartCommand {
+		bisectStart(args)
+		return
+	}
+	if readStateFile(bisectStartFile) == "" {
+		fmt.Println(noBisectInProgress)
+		return
+	}
+
+	switch subcommand {
+	case bisectGoodCommand, bisectBadCommand, bisectSkipCommand:
+		rev := common.HEAD_FILE_NAME
+		if len(args) > 0 {
+			rev = args[0]
+		}
+		hash, err := resolveRevision(rev)
+		if err != nil {
+			fmt.Println(commitDoesNotExist)
+			return
+		}
+		markBisectCommit(subcommand, hash)
+		bisectNext()
+	case bisectResetCommand:
+		bisectReset()
+	case bisectRunCommand:
+		if len(args) == 0 {
+			fmt.Println(bisectUsage)
+			return
+		}
+		bisectRun(args)
+	default:
+		fmt.Println(bisectUsage)
+	}
+}
+
+// bisectStart remembers where HEAD was so reset can return there, then takes the bad and good commits
+func bisectStart(args []string) {
+	if readStateFile(bisectStartFile) != "" {
+		fmt.Println(bisectInProgress)
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+
+	var hashes []string
+	for _, arg := range args {
+		hash, err := resolveRevision(arg)
+		if err != nil {
+			fmt.Println(commitDoesNotExist)
+			return
+		}
+		hashes = append(hashes, hash)
+	}
+
+	ref, hash := readHead()
+	if ref == "" {
+		writeStateFile(bisectStartFile, hash)
+	} else {
+		writeStateFile(bisectStartFile, ref)
+	}
+	for i, hash := range hashes {
+		if i == 0 {
+			markBisectCommit(bisectBadCommand, hash)
+		} else {
+			markBisectCommit(bisectGoodCommand, hash)
+		}
+	}
+	bisectNext()
+}
+
+// markBisectCommit records a verdict; there is one bad commit, the newest one marked, and any number of good
+// and skipped ones
+func markBisectCommit(verdict string, hash string) {
+	switch verdict {
+	case bisectBadCommand:
+		writeStateFile(bisectBadFile, hash)
+	case bisectGoodCommand:
+		writeStateFile(bisectGoodFile, strings.Join(append(readBisectList(bisectGoodFile), hash), "\n"))
+	case bisectSkipCommand:
+		writeStateFile(bisectSkipFile, strings.Join(append(readBisectList(bisectSkipFile), hash), "\n"))
+	}
+}
+
+func readBisectList(name string) []string {
+	return strings.Fields(readStateFile(name))
+}
+
+// bisectNext checks out the next commit to test and reports false once there is nothing left to test, either
+// because the search is over or it can't start yet
+func bisectNext() bool {
+	bad := readStateFile(bisectBadFile)
+	goods := readBisectList(bisectGoodFile)
+	if bad == "" || len(goods) == 0 {
+		fmt.Println(waitingForBadAndGood)
+		return false
+	}
+
+	// The suspects are the ancestors of the bad commit that no good commit has
+	candidates := ancestorsOf(bad)
+	for _, good := range goods {
+		if candidates[good] {
+			for hash := range ancestorsOf(good) {
+				delete(candidates, hash)
+			}
+		}
+	}
+	if !candidates[bad] {
+		fmt.Printf(badIsAncestorOfGood, shortHash(bad))
+		return false
+	}
+
+	if len(candidates) == 1 {
+		printFirstBadCommit(bad)
+		return false
+	}
+
+	skipped := map[string]bool{}
+	for _, hash := range readBisectList(bisectSkipFile) {
+		skipped[hash] = true
+	}
+	next, below := bisectMidpoint(candidates, bad, skipped)
+	if next == "" {
+		fmt.Println(onlySkippedLeft)
+		for _, hash := range sortedKeys(candidates) {
+			if skipped[hash] || hash == bad {
+				fmt.Println(hash)
+			}
+		}
+		return false
+	}
+
+	commit, err := readCommit(next)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return false
+	}
+	checkoutCommit(next)
+	moveHead("", next, "checkout: moving to "+next)
+
+	// Whatever the verdict, at most the larger side of the split is left
+	left := below - 1
+	if rest := len(candidates) - below; rest > left {
+		left = rest
+	}
+	fmt.Printf(bisecting, left, bisectSteps(left), shortHash(next), commitSubject(commit))
+	return true
+}
+
+// bisectMidpoint picks the testable candidate whose verdict splits the candidates most evenly and returns it
+// with the number of candidates it reaches, itself included
+func bisectMidpoint(candidates map[string]bool, bad string, skipped map[string]bool) (string, int) {
+	parents := candidateParents(candidates)
+	best, bestBelow, bestScore := "", 0, -1
+	for _, hash := range sortedKeys(candidates) {
+		if hash == bad || skipped[hash] {
+			continue
+		}
+		below := countAncestors(hash, parents)
+		score := below
+		if above := len(candidates) - below; above < score {
+			score = above
+		}
+		if score > bestScore {
+			best, bestBelow, bestScore = hash, below, score
+		}
+	}
+	return best, bestBelow
+}
+
+// candidateParents reads every candidate once and keeps the parents that are candidates too, so the ancestors
+// of each can be counted without going back to the object store
+func candidateParents(candidates map[string]bool) map[string][]string {
+	parents := make(map[string][]string, len(candidates))
+	for hash := range candidates {
+		commit, err := readCommit(hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, parent := range commit.parents {
+			if candidates[parent] {
+				parents[hash] = append(parents[hash], parent)
+			}
+		}
+	}
+	return parents
+}
+
+// countAncestors counts the candidates reachable from hash through parents, itself included
+func countAncestors(hash string, parents map[string][]string) int {
+	seen := map[string]bool{hash: true}
+	stack := []string{hash}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, parent := range parents[current] {
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return len(seen)
+}
+
+func bisectSteps(n int) int {
+	steps := 0
+	for n > 0 {
+		n /= 2
+		steps++
+	}
+	return steps
+}
+
+func printFirstBadCommit(hash string) {
+	commit, err := readCommit(hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf(firstBadCommit, hash)
+	fmt.Printf(logMessage, hash, commit.author.name, commit.message)
+}
+
+// bisectReset checks out what HEAD was on before the bisect started and forgets the verdicts
+func bisectReset() {
+	start := readStateFile(bisectStartFile)
+	ref, hash := start, ""
+	if isHexHash(start) {
+		ref, hash = "", start
+	} else {
+		hash = readRef(start)
+	}
+
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+	current := headCommit()
+	checkoutCommit(hash)
+	moveHead(ref, hash, "bisect: reset")
+	for _, name := range []string{bisectStartFile, bisectBadFile, bisectGoodFile, bisectSkipFile} {
+		removeStateFile(name)
+	}
+	fmt.Printf(bisectResetTo, shortHash(current), headLabel())
+}
+
+// bisectRun repeats the search by itself, taking the exit code of the script as the verdict on every commit
+func bisectRun(args []string) {
+	// The commit start or the last verdict checked out is the first one the script sees
+	if readStateFile(bisectBadFile) == "" || len(readBisectList(bisectGoodFile)) == 0 {
+		fmt.Println(waitingForBadAndGood)
+		return
+	}
+	for {
+		fmt.Printf(runningScript, strings.Join(args, " "))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = workTreeDir
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		code := 0
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				fmt.Printf(bisectRunFailed, err)
+				return
+			}
+			code = exitErr.ExitCode()
+		}
+
+		verdict := bisectBadCommand
+		switch {
+		case code == 0:
+			verdict = bisectGoodCommand
+		case code == bisectRunSkipCode:
+			verdict = bisectSkipCommand
+		case code < 0 || code > bisectRunLastBadCode:
+			fmt.Printf(bisectRunCannotContinue, args[0], code)
+			return
+		}
+		markBisectCommit(verdict, headCommit())
+		if !bisectNext() {
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// TestBisectFindsFirstBadCommit marks each checked out commit by its content until bisect names the first one
+// that has the bug
+func TestBisectFindsFirstBadCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  int
+		firstBad int
+		// skipFirst skips the first commit bisect checks out, which ties between equally good splits make vary
+		skipFirst bool
+	}{
+		{name: "second commit", commits: 8, firstBad: 1},
+		{name: "middle", commits: 20, firstBad: 11},
+		{name: "last commit", commits: 9, firstBad: 8},
+		{name: "skipping a commit", commits: 16, firstBad: 11, skipFirst: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			hashes := make([]string, test.commits)
+			for i := range hashes {
+				state := "works"
+				if i >= test.firstBad {
+					state = "broken"
+				}
+				hashes[i] = repo.commitFiles(t, fmt.Sprint("commit ", i), map[string]string{
+					"state": state + "\n", "n": fmt.Sprintln(i),
+				})
+			}
+
+			output := runCommand(t, common.BISECT, bisectStartCommand, hashes[len(hashes)-1], hashes[0])
+			skipped := false
+			for steps := 0; !strings.Contains(output, "is the first bad commit"); steps++ {
+				if steps > test.commits {
+					t.Fatalf("bisect did not finish, last printed %q", output)
+				}
+				verdict := bisectGoodCommand
+				switch {
+				case test.skipFirst && steps == 0:
+					verdict, skipped = bisectSkipCommand, true
+				case repo.readWorkTreeFile(t, "state") == "broken\n":
+					verdict = bisectBadCommand
+				}
+				output = runCommand(t, common.BISECT, verdict)
+			}
+
+			if test.skipFirst && !skipped {
+				t.Error("bisect finished before anything was skipped")
+			}
+			if want := fmt.Sprintf(firstBadCommit, hashes[test.firstBad]); !strings.Contains(output, want) {
+				t.Errorf("bisect printed %q, want %q", output, want)
+			}
+			runCommand(t, common.BISECT, bisectResetCommand)
+			if branch := currentBranch(); branch != common.DEFAULT_BRANCH {
+				t.Errorf("reset left HEAD on %q", branch)
+			}
+		})
+	}
+}
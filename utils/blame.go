@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"version_control_go/common"
+)
+
+const pathWasNotPassed = "Path was not passed."
+const noSuchPathInHead = "There is no path '%s' in HEAD.\n"
+const pathspecNamesSeveralFiles = "'%s' names %d files in HEAD, blame needs exactly one.\n"
+const invalidLineRange = "Invalid line range '%s', expected <start>,<end>.\n"
+const lineRangeOutOfFile = "The file '%s' has only %d lines.\n"
+const blameOutputLine = "%s (%-*s %s %*d) %s"
+
+const blameDateLayout = "2006-01-02 15:04:05 -0700"
+
+// blamedLine is a line of the blamed file, final is its index in HEAD's version and current its index in the
+// version of the commit it is waiting at; once blamed, origin is its index in the commit that introduced it
+type blamedLine struct {
+	final   int
+	current int
+	commit  string
+	origin  int
+}
+
+// blameCase prints every line of a file at HEAD with the commit, author and date that last changed it
+func blameCase(consoleArgs []string) {
+	requireWorkTree()
+
+	porcelain := false
+	lineRange := ""
+	filePath := ""
+	args := consoleArgs[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == common.PORCELAIN_FLAG:
+			porcelain = true
+		case args[i] == common.LINE_RANGE_FLAG:
+			if i+1 == len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, common.LINE_RANGE_FLAG)
+				return
+			}
+			i++
+			lineRange = args[i]
+		case strings.HasPrefix(args[i], common.LINE_RANGE_FLAG):
+			lineRange = strings.TrimPrefix(args[i], common.LINE_RANGE_FLAG)
+		default:
+			filePath = args[i]
+		}
+	}
+	if filePath == "" {
+		fmt.Println(pathWasNotPassed)
+		return
+	}
+	spec, err := parsePathspec([]string{filePath})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	head := headCommit()
+	if head == "" {
+		fmt.Println(noCommitsYet)
+		return
+	}
+
+	// The path is a pathspec like everywhere else, but it has to name a single file
+	headHashes := entryHashes(treeEntriesOfCommit(head))
+	matched := spec.filter(sortedKeys(headHashes))
+	if len(matched) == 0 {
+		fmt.Printf(noSuchPathInHead, filePath)
+		return
+	}
+	if len(matched) > 1 {
+		fmt.Printf(pathspecNamesSeveralFiles, filePath, len(matched))
+		return
+	}
+	trackedPath := matched[0]
+	blobHash := headHashes[trackedPath]
+	content := splitLines(string(readBlobOrEmpty(blobHash)))
+
+	start, end := 1, len(content)
+	if lineRange != "" {
+		var ok bool
+		if start, end, ok = parseLineRange(lineRange, len(content)); !ok {
+			fmt.Printf(invalidLineRange, lineRange)
+			return
+		}
+		if end > len(content) {
+			fmt.Printf(lineRangeOutOfFile, trackedPath, len(content))
+			return
+		}
+	}
+
+	lines := blameFile(head, trackedPath, len(content))[start-1 : end]
+	if porcelain {
+		printPorcelainBlame(lines, content, trackedPath)
+	} else {
+		printBlame(lines, content)
+	}
+}
+
+// parseLineRange reads "<start>,<end>" counted from 1, where a missing end means the last line
+func parseLineRange(value string, lineCount int) (int, int, bool) {
+	startText, endText, found := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 1 {
+		return 0, 0, false
+	}
+	end := lineCount
+	if found && endText != "" {
+		if end, err = strconv.Atoi(endText); err != nil {
+			return 0, 0, false
+		}
+	}
+	if end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// blameFile walks history back from head, handing each line to a parent that has it unchanged; a line no parent
+// has was introduced by the commit it is waiting at
+func blameFile(head string, filePath string, lineCount int) []blamedLine {
+	result := make([]blamedLine, lineCount)
+	pending := map[string][]blamedLine{}
+	for i := range result {
+		pending[head] = append(pending[head], blamedLine{final: i, current: i})
+	}
+
+	fileLines := map[string][]string{}
+	linesAt := func(hash string) []string {
+		if lines, ok := fileLines[hash]; ok {
+			return lines
+		}
+		lines := splitLines(string(readBlobOrEmpty(entryHashes(treeEntriesOfCommit(hash))[filePath])))
+		fileLines[hash] = lines
+		return lines
+	}
+
+	// Newer commits go first so a commit usually gets all its lines from its children before it is looked at;
+	// lines arriving later just bring it back into pending
+	commitTimes := map[string]time.Time{}
+	for len(pending) > 0 {
+		hash := newestPending(pending, commitTimes)
+		lines := pending[hash]
+		delete(pending, hash)
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, parent := range commit.parents {
+			if len(lines) == 0 {
+				break
+			}
+			matched := map[int]int{}
+			for _, match := range matchLines(linesAt(parent), linesAt(hash)) {
+				matched[match[1]] = match[0]
+			}
+
+			var left []blamedLine
+			for _, line := range lines {
+				if parentIndex, ok := matched[line.current]; ok {
+					line.current = parentIndex
+					pending[parent] = append(pending[parent], line)
+				} else {
+					left = append(left, line)
+				}
+			}
+			lines = left
+		}
+
+		for _, line := range lines {
+			line.commit, line.origin = hash, line.current
+			result[line.final] = line
+		}
+	}
+	return result
+}
+
+// newestPending picks the pending commit with the latest committer date
+func newestPending(pending map[string][]blamedLine, commitTimes map[string]time.Time) string {
+	newest := ""
+	for hash := range pending {
+		if _, ok := commitTimes[hash]; !ok {
+			commit, err := readCommit(hash)
+			if err != nil {
+				log.Fatal(err)
+			}
+			commitTimes[hash] = commit.committer.when
+		}
+		if newest == "" || commitTimes[hash].After(commitTimes[newest]) ||
+			commitTimes[hash].Equal(commitTimes[newest]) && hash < newest {
+			newest = hash
+		}
+	}
+	return newest
+}
+
+func printBlame(lines []blamedLine, content []string) {
+	authors := map[string]signature{}
+	nameWidth := 0
+	for _, line := range lines {
+		if _, ok := authors[line.commit]; ok {
+			continue
+		}
+		commit, err := readCommit(line.commit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authors[line.commit] = commit.author
+		if len(commit.author.name) > nameWidth {
+			nameWidth = len(commit.author.name)
+		}
+	}
+	numberWidth := len(strconv.Itoa(len(content)))
+
+	for _, line := range lines {
+		author := authors[line.commit]
+		fmt.Printf(blameOutputLine, shortHash(line.commit), nameWidth, author.name, author.when.Format(blameDateLayout),
+			numberWidth, line.final+1, withNewline(content[line.final]))
+	}
+}
+
+// printPorcelainBlame prints "<commit> <original line> <final line>" for every line, the commit's details the
+// first time it shows up and the line itself after a tab
+func printPorcelainBlame(lines []blamedLine, content []string, filePath string) {
+	seen := map[string]bool{}
+	for _, line := range lines {
+		fmt.Printf("%s %d %d\n", line.commit, line.origin+1, line.final+1)
+		if !seen[line.commit] {
+			seen[line.commit] = true
+			commit, err := readCommit(line.commit)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("author %s\n", commit.author.name)
+			fmt.Printf("author-time %d\n", commit.author.when.Unix())
+			fmt.Printf("author-tz %s\n", commit.author.when.Format("-0700"))
+			fmt.Printf("committer %s\n", commit.committer.name)
+			fmt.Printf("committer-time %d\n", commit.committer.when.Unix())
+			fmt.Printf("committer-tz %s\n", commit.committer.when.Format("-0700"))
+			fmt.Printf("summary %s\n", commitSubject(commit))
+			if len(commit.parents) == 0 {
+				fmt.Println("boundary")
+			}
+			fmt.Printf("filename %s\n", filePath)
+		}
+		fmt.Print("\t" + withNewline(content[line.final]))
+	}
+}
+
+// withNewline ends a line with a newline, which the last line of a file may lack
+func withNewline(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// blameTestHistory commits f on base by alice, changes its second line on a side branch by bob and its fourth
+// on master by carol, then merges both adding a first line; it returns the commits by name
+func blameTestHistory(t *testing.T, repo testRepo) map[string]string {
+	t.Helper()
+	commits := map[string]string{}
+	commitAs := func(name string, author string, content string) {
+		setConfig(common.USER_NAME_KEY, author)
+		commits[name] = repo.commitFiles(t, name, map[string]string{"f": content})
+	}
+
+	commitAs("base", "alice", "1\n2\n3\n4\n5\n")
+	repo.commitFiles(t, "other file", map[string]string{"g": "g\n"})
+	runCommand(t, common.CHECKOUT, commits["base"])
+	commitAs("side", "bob", "1\ntwo\n3\n4\n5\n")
+	runCommand(t, common.CHECKOUT, common.DEFAULT_BRANCH)
+	commitAs("master", "carol", "1\n2\n3\nfour\n5\n")
+
+	writeStateFile(common.MERGE_HEAD_FILE_NAME, commits["side"])
+	commitAs("merge", "dave", "zero\n1\ntwo\n3\nfour\n5\n")
+	if parents := len(mustReadCommit(t, commits["merge"]).parents); parents != 2 {
+		t.Fatalf("the merge has %d parents", parents)
+	}
+	return commits
+}
+
+func mustReadCommit(t *testing.T, hash string) commitObject {
+	t.Helper()
+	commit, err := readCommit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestBlamePorcelain(t *testing.T) {
+	repo := newTestRepo(t)
+	commits := blameTestHistory(t, repo)
+
+	tests := []struct {
+		name string
+		args []string
+		// want has "<commit name> <original line> <final line>" for every blamed line
+		want []string
+	}{
+		{name: "whole file", args: []string{"f"}, want: []string{
+			"merge 1 1", "base 1 2", "side 2 3", "base 3 4", "master 4 5", "base 5 6",
+		}},
+		{name: "line range", args: []string{common.LINE_RANGE_FLAG, "3,5", "f"}, want: []string{
+			"side 2 3", "base 3 4", "master 4 5",
+		}},
+		{name: "open ended range", args: []string{common.LINE_RANGE_FLAG + "5", "f"}, want: []string{
+			"master 4 5", "base 5 6",
+		}},
+		{name: "pathspec", args: []string{"./f"}, want: []string{
+			"merge 1 1", "base 1 2", "side 2 3", "base 3 4", "master 4 5", "base 5 6",
+		}},
+	}
+	names := map[string]string{}
+	for name, hash := range commits {
+		names[hash] = name
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := runCommand(t, common.BLAME, append([]string{common.PORCELAIN_FLAG}, test.args...)...)
+			var got []string
+			headers := map[string]int{}
+			for _, line := range strings.Split(output, "\n") {
+				var hash string
+				var origin, final int
+				if _, err := fmt.Sscanf(line, "%s %d %d", &hash, &origin, &final); err == nil && names[hash] != "" {
+					got = append(got, fmt.Sprintf("%s %d %d", names[hash], origin, final))
+				}
+				if strings.HasPrefix(line, "summary ") {
+					headers[strings.TrimPrefix(line, "summary ")]++
+				}
+			}
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("blame %q = %q, want %q", test.args, got, test.want)
+			}
+			for summary, count := range headers {
+				if count != 1 {
+					t.Errorf("the details of %q were printed %d times", summary, count)
+				}
+			}
+		})
+	}
+}
+
+func TestBlameOutput(t *testing.T) {
+	repo := newTestRepo(t)
+	commits := blameTestHistory(t, repo)
+
+	output := runCommand(t, common.BLAME, "f")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("blame printed %d lines, want 6:\n%s", len(lines), output)
+	}
+	want := []struct{ commit, author, content string }{
+		{"merge", "dave ", "zero"}, {"base", "alice", "1"}, {"side", "bob  ", "two"},
+		{"base", "alice", "3"}, {"master", "carol", "four"}, {"base", "alice", "5"},
+	}
+	for i, line := range lines {
+		prefix := shortHash(commits[want[i].commit]) + " (" + want[i].author + " "
+		suffix := fmt.Sprintf(" %d) %s", i+1, want[i].content)
+		if !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, suffix) {
+			t.Errorf("line %d = %q, want %q...%q", i+1, line, prefix, suffix)
+		}
+	}
+}
+
+func TestBlameRefuses(t *testing.T) {
+	repo := newTestRepo(t)
+	blameTestHistory(t, repo)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "no path", args: nil, want: pathWasNotPassed},
+		{name: "unknown path", args: []string{"missing"}, want: fmt.Sprintf(noSuchPathInHead, "missing")},
+		{name: "several files", args: []string{"*"}, want: fmt.Sprintf(pathspecNamesSeveralFiles, "*", 2)},
+		{name: "reversed range", args: []string{common.LINE_RANGE_FLAG, "4,2", "f"}, want: fmt.Sprintf(invalidLineRange, "4,2")},
+		{name: "range past the end", args: []string{common.LINE_RANGE_FLAG, "2,9", "f"}, want: fmt.Sprintf(lineRangeOutOfFile, "f", 6)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := runCommand(t, common.BLAME, test.args...); strings.TrimSuffix(got, "\n") != strings.TrimSuffix(test.want, "\n") {
+				t.Errorf("blame %q printed %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"version_control_go/common"
+)
+
+const commitIdWasNotPassed = "Commit id was not passed."
+const commitDoesNotExist = "Commit does not exist."
+const switchedToCommit = "Switched to commit %s.\n"
+const switchedToBranch = "Switched to branch '%s'.\n"
+const localChangesWouldBeOverwritten = "Your local changes would be overwritten. Commit them first."
+const untrackedWouldBeOverwritten = "The following untracked work tree files would be overwritten:\n%sPlease move or remove them first.\n"
+
+func checkoutCase(consoleArgs []string) {
+	requireWorkTree()
+
+	if len(consoleArgs) < 3 {
+		fmt.Println(commitIdWasNotPassed)
+		return
+	}
+	rev := consoleArgs[2]
+
+	commitHash, err := resolveRevision(rev)
+	if err != nil {
+		fmt.Println(commitDoesNotExist)
+		return
+	}
+
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+
+	from := currentBranch()
+	if from == "" {
+		from = headCommit()
+	}
+	reflogMessage := "checkout: moving from " + from + " to " + rev
+	if refuseUntrackedOverwrite(treePaths(treeEntriesOfCommit(commitHash))) {
+		return
+	}
+	checkoutCommit(commitHash)
+
+	// A branch name keeps HEAD attached so later commits move the branch
+	if isLocalBranch(rev) {
+		moveHead(common.HEADS_DIR+"/"+rev, commitHash, reflogMessage)
+		fmt.Printf(switchedToBranch, rev)
+		return
+	}
+	moveHead("", commitHash, reflogMessage)
+	fmt.Printf(switchedToCommit, commitHash)
+}
+
+// checkoutCommit replaces the tracked files and the index with the tree of commitHash
+func checkoutCommit(commitHash string) {
+	checkoutTree(treeEntriesOfCommit(commitHash))
+}
+
+// checkoutTree makes the tracked files and the index match entries, exiting before it writes anything when that
+// would overwrite an untracked file
+func checkoutTree(entries []treeEntry) {
+	if refuseUntrackedOverwrite(treePaths(entries)) {
+		os.Exit(1)
+	}
+
+	newPaths := map[string]bool{}
+	for _, entry := range entries {
+		newPaths[entry.path] = true
+	}
+
+	// Remove files that are tracked now but not in the target tree
+	for _, entry := range readIndex() {
+		if newPaths[entry.path] {
+			continue
+		}
+		if err := os.Remove(workTreeFile(entry.path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+	}
+
+	for _, entry := range entries {
+		data, err := readObject(entry.hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filePath := workTreeFile(entry.path)
+		createDir(filepath.Dir(filePath))
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	writeIndex(entries)
+	removeStateFile(common.CONFLICTS_FILE_NAME)
+}
+
+// refuseUntrackedOverwrite lists the paths that exist in the work tree without being tracked and reports whether
+// there were any
+func refuseUntrackedOverwrite(paths []string) bool {
+	tracked := entryHashes(readIndex())
+	var builder strings.Builder
+	for _, path := range paths {
+		if _, ok := tracked[path]; ok {
+			continue
+		}
+		if _, err := os.Lstat(workTreeFile(path)); err == nil {
+			builder.WriteString("\t" + path + "\n")
+		}
+	}
+	if builder.Len() == 0 {
+		return false
+	}
+	fmt.Printf(untrackedWouldBeOverwritten, builder.String())
+	return true
+}
+
+func treePaths(entries []treeEntry) []string {
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.path)
+	}
+	return paths
+}
+
+// treeEntriesOfCommit returns the files of a commit, none for the empty hash of an unborn branch
+func treeEntriesOfCommit(commitHash string) []treeEntry {
+	if commitHash == "" {
+		return nil
+	}
+	commit, err := readCommit(commitHash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := readTree(commit.tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return entries
+}
+
+// hasLocalChanges reports whether the index differs from HEAD or a tracked file differs from the index
+func hasLocalChanges() bool {
+	headHashes := map[string]string{}
+	for _, entry := range treeEntriesOfCommit(headCommit()) {
+		headHashes[entry.path] = entry.hash
+	}
+
+	entries := readIndex()
+	if len(entries) != len(headHashes) {
+		return true
+	}
+
+	for _, entry := range entries {
+		if entry.hash == "" || headHashes[entry.path] != entry.hash {
+			return true
+		}
+		data, err := os.ReadFile(workTreeFile(entry.path))
+		if err != nil || hashBlob(data) != entry.hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"version_control_go/common"
+)
+
+const revisionWasNotPassed = "Revision was not passed."
+const commitIsAMerge = "Commit %s is a merge, pick one of its parents' changes by hand.\n"
+const pickIsEmpty = "The cherry-pick of %s changes nothing, it was already applied.\n"
+const pickedCommit = "[%s %s] %s\n"
+const couldNotApply = "Could not apply %s... %s\nAfter resolving the conflicts, mark the paths with 'add <path>' and run 'commit'," +
+	" or use 'cherry-pick --skip' or 'cherry-pick --abort'.\n"
+const noPickInProgress = "No %s in progress.\n"
+const pickAborted = "The %s of %s was aborted, back on %s.\n"
+const pickSkipped = "Skipped the %s of %s.\n"
+
+const cherryPickTrailer = "(cherry picked from commit %s)"
+
+// cherryPickCase applies the changes a commit made to its parent on top of HEAD as a new commit by the same author
+func cherryPickCase(consoleArgs []string) {
+	requireWorkTree()
+
+	if len(consoleArgs) < 3 {
+		fmt.Println(revisionWasNotPassed)
+		return
+	}
+	if consoleArgs[2] == common.ABORT_FLAG || consoleArgs[2] == common.SKIP_FLAG {
+		stopPick(common.CHERRY_PICK, common.CHERRY_PICK_HEAD_FILE_NAME, consoleArgs[2])
+		return
+	}
+	hash, err := resolveRevision(consoleArgs[2])
+	if err != nil {
+		fmt.Println(commitDoesNotExist)
+		return
+	}
+	commit, err := readCommit(hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(commit.parents) > 1 {
+		fmt.Printf(commitIsAMerge, shortHash(hash))
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+
+	parent := ""
+	if len(commit.parents) == 1 {
+		parent = commit.parents[0]
+	}
+	message := strings.TrimRight(commit.message, "\n") + "\n\n" + fmt.Sprintf(cherryPickTrailer, hash)
+	result := applyCommitChanges(parent, hash, shortHash(hash)+" ("+commitSubject(commit)+")")
+
+	if len(result.conflicts) > 0 {
+		// commit finishes the pick with the original author once the conflicts are resolved
+		writeStateFile(common.CHERRY_PICK_HEAD_FILE_NAME, hash)
+		writeStateFile(common.MERGE_MSG_FILE_NAME, message)
+		fmt.Printf(couldNotApply, shortHash(hash), commitSubject(commit))
+		return
+	}
+
+	newHash, ok := commitPickedTree(result.entries, commit.author, message, "cherry-pick: "+commitSubject(commit))
+	if !ok {
+		fmt.Printf(pickIsEmpty, shortHash(hash))
+		return
+	}
+	fmt.Printf(pickedCommit, headLabel(), shortHash(newHash), commitSubject(commit))
+}
+
+// stopPick ends a cherry-pick or revert stopped by conflicts without committing it, putting the index and the
+// work tree back to HEAD; a single commit is picked at a time, so skipping it ends the pick just as aborting does
+func stopPick(command string, stateFile string, flag string) {
+	hash := readStateFile(stateFile)
+	if hash == "" {
+		fmt.Printf(noPickInProgress, command)
+		return
+	}
+	checkoutCommit(headCommit())
+	clearPickState()
+
+	if flag == common.SKIP_FLAG {
+		fmt.Printf(pickSkipped, command, shortHash(hash))
+	} else {
+		fmt.Printf(pickAborted, command, shortHash(hash), headLabel())
+	}
+}
+
+// clearPickState forgets the commit a cherry-pick or revert stopped at along with its message
+func clearPickState() {
+	removeStateFile(common.MERGE_MSG_FILE_NAME)
+	removeStateFile(common.CHERRY_PICK_HEAD_FILE_NAME)
+	removeStateFile(common.REVERT_HEAD_FILE_NAME)
+}
+
+// applyCommitChanges three-way merges the change from one commit to another into HEAD and checks out the result;
+// from is "" for the parent of a root commit
+func applyCommitChanges(from string, to string, label string) mergeResult {
+	result := mergeCommitChanges(from, to, label)
+	applyMergeResult(result)
+	return result
+}
+
+// mergeCommitChanges is the merge applyCommitChanges checks out
+func mergeCommitChanges(from string, to string, label string) mergeResult {
+	return mergeTrees(treeEntriesOfCommit(from), treeEntriesOfCommit(headCommit()), treeEntriesOfCommit(to), common.HEAD_FILE_NAME, label)
+}
+
+// commitPickedTree commits entries on top of HEAD keeping author, and reports false when they match HEAD's tree
+func commitPickedTree(entries []treeEntry, author signature, message string, reflogMessage string) (string, bool) {
+	treeHash := writeTree(entries)
+	head := headCommit()
+	var parents []string
+	if head != "" {
+		headObject, err := readCommit(head)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if headObject.tree == treeHash {
+			return "", false
+		}
+		parents = append(parents, head)
+	}
+
+	hash := writeCommit(commitObject{
+		tree:      treeHash,
+		parents:   parents,
+		author:    author,
+		committer: newSignature(getConfig(common.USER_NAME_KEY)),
+		message:   message,
+	})
+	updateHead(hash, reflogMessage)
+	return hash, true
+}
+
+// commitSubject is the first line of a commit message
+func commitSubject(commit commitObject) string {
+	return strings.SplitN(commit.message, "\n", 2)[0]
+}
+
+// headLabel names what HEAD is on for messages, the branch or "detached HEAD"
+func headLabel() string {
+	if branch := currentBranch(); branch != "" {
+		return branch
+	}
+	return "detached HEAD"
+}
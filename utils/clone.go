@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"version_control_go/common"
+)
+
+const repositoryWasNotPassed = "Repository path was not passed."
+const destinationExists = "Destination path '%s' already exists and is not an empty directory.\n"
+const cloningInto = "Cloning into '%s'...\n"
+const clonedEmptyRepository = "You appear to have cloned an empty repository."
+
+// repoDirAt returns the repository stored at path, either a work tree holding vcs or a bare repository
+func repoDirAt(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if candidate := filepath.Join(absPath, common.VCS_DIR_NAME); isDir(candidate) {
+		return candidate, nil
+	}
+	if isBareRepoDir(absPath) || filepath.Base(absPath) == common.VCS_DIR_NAME && isDir(absPath) {
+		return absPath, nil
+	}
+	return "", errNotARepository
+}
+
+func cloneCase(consoleArgs []string) {
+	if len(consoleArgs) < 3 {
+		fmt.Println(repositoryWasNotPassed)
+		return
+	}
+	source := consoleArgs[2]
+
+	transport, err := openTransport(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, common.NOT_A_REPOSITORY, source)
+		os.Exit(1)
+	}
+
+	// Without a destination the repository is cloned into a directory named after it
+	dest := path.Base(strings.TrimSuffix(filepath.ToSlash(filepath.Clean(source)), "/"+common.VCS_DIR_NAME))
+	if len(consoleArgs) > 3 {
+		dest = consoleArgs[3]
+	}
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		fmt.Printf(destinationExists, dest)
+		return
+	}
+	fmt.Printf(cloningInto, dest)
+
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	advertisement, err := transport.advertisedRefs()
+	if err != nil {
+		exitWithError(err)
+	}
+	branches, defaultBranch := advertisement.branches, advertisement.head
+	if _, err := hasherByName(advertisement.hashName); err != nil {
+		exitWithError(err)
+	}
+
+	// The clone names its objects the way the source does so fetched objects keep their names
+	repoDir = filepath.Join(absDest, common.VCS_DIR_NAME)
+	workTreeDir = absDest
+	initRepoDir(repoDir, false, advertisement.hashName)
+
+	fetchBranchObjects(transport, branches)
+
+	// Remote branches are kept apart from local ones so a later fetch can update them
+	for name, hash := range branches {
+		moveRef(remoteRefName(common.DEFAULT_REMOTE, name), hash, "clone: from "+source)
+	}
+
+	remoteURL := source
+	if _, isFile := transport.(fileTransport); isFile {
+		if remoteURL, err = filepath.Abs(source); err != nil {
+			log.Fatal(err)
+		}
+	}
+	setConfig(fmt.Sprintf(common.REMOTE_KEY, common.DEFAULT_REMOTE), remoteURL)
+
+	if defaultBranch == "" || branches[defaultBranch] == "" {
+		fmt.Println(clonedEmptyRepository)
+		return
+	}
+
+	moveRef(common.HEADS_DIR+"/"+defaultBranch, branches[defaultBranch], "clone: from "+source)
+	moveHead(common.HEADS_DIR+"/"+defaultBranch, branches[defaultBranch], "clone: from "+source)
+	checkoutCommit(branches[defaultBranch])
+}
+
+func remoteRefName(remote string, branch string) string {
+	return common.REMOTES_DIR + "/" + remote + "/" + branch
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"version_control_go/common"
+)
+
+const noCommitsYet = "No commits yet."
+const changesCommited = "Changes are committed."
+const logMessage = "commit %s\nAuthor: %s\n%s\n\n"
+const nothingToCommit = "Nothing to commit."
+const noFilesTracked = "No files are tracked now! Use 'add'."
+const abortingEmptyMessage = "Aborting commit due to empty commit message."
+const canNotReadMessage = "Can't read the message from '%s': %v\n"
+const nothingToAmend = "You have nothing to amend."
+const cannotAmendDuringMerge = "You are in the middle of a merge, cannot amend."
+const amendedCommit = "Amended %s into %s.\n"
+const unknownCommitOption = "Unknown option '%s'.\n"
+const extraCommitArgument = "Unexpected argument '%s', quote a message of several words or use -m.\n"
+
+const commitEditMessageFile = "COMMIT_EDITMSG"
+const stdinFileName = "-"
+
+const commitTemplateHelp = `
+# Please enter the commit message for your changes. Lines starting
+# with '#' will be ignored, and an empty message aborts the commit.
+#
+# On %s
+# Changes to be committed:
+`
+
+// commitOptions are what the arguments of commit ask for; message is empty when it should come from the editor
+type commitOptions struct {
+	message string
+	amend   bool
+	all     bool
+}
+
+// parseCommitArgs reads "--amend", "-a", "-m <message>", which may be repeated for more paragraphs,
+// "-F <file>" or "-F -" for stdin, and a single bare message as before
+func parseCommitArgs(args []string) (commitOptions, bool) {
+	var options commitOptions
+	var paragraphs []string
+	bareMessage := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case common.AMEND_FLAG:
+			options.amend = true
+		case common.ALL_FLAG:
+			options.all = true
+		case common.MESSAGE_FLAG, common.FILE_FLAG:
+			if i+1 == len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, args[i])
+				return options, false
+			}
+			i++
+			if args[i-1] == common.MESSAGE_FLAG {
+				paragraphs = append(paragraphs, args[i])
+				continue
+			}
+			message, err := readMessageFile(args[i])
+			if err != nil {
+				fmt.Printf(canNotReadMessage, args[i], err)
+				return options, false
+			}
+			paragraphs = append(paragraphs, message)
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf(unknownCommitOption, args[i])
+				return options, false
+			}
+			if bareMessage {
+				fmt.Printf(extraCommitArgument, args[i])
+				return options, false
+			}
+			bareMessage = true
+			paragraphs = append(paragraphs, args[i])
+		}
+	}
+	options.message = strings.Join(paragraphs, "\n\n")
+	return options, true
+}
+
+// readMessageFile reads a message from a file, or from stdin for "-"
+func readMessageFile(name string) (string, error) {
+	if name == stdinFileName {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(name)
+	return string(data), err
+}
+
+// cleanMessage drops trailing spaces and the blank lines around a message given on the command line or in a file
+func cleanMessage(message string) string {
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func commitCase(consoleArgs []string) {
+	requireWorkTree()
+
+	options, ok := parseCommitArgs(consoleArgs[2:])
+	if !ok || refuseUnmergedPaths("Committing") {
+		return
+	}
+	mergeHead := readStateFile(common.MERGE_HEAD_FILE_NAME)
+
+	// Get currently staged files from index.txt
+	entries := readIndex()
+	if len(entries) == 0 {
+		fmt.Println(noFilesTracked)
+		return
+	}
+	entries = fillLegacyIndexEntries(entries)
+	// What -a stages only reaches the index once the commit exists, so a commit that stops keeps the index as it was
+	if options.all {
+		entries = stageTrackedChanges(entries)
+	}
+
+	if options.amend {
+		amendCommit(options, mergeHead, entries)
+		return
+	}
+
+	// If the tree is the same as the one in the latest commit then nothing has changed
+	treeHash := writeTree(entries)
+	parentHash := headCommit()
+	var parents []string
+	if parentHash != "" {
+		parent, err := readCommit(parentHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if parent.tree == treeHash && mergeHead == "" {
+			// A pick or revert resolved to what HEAD already has is over, there is nothing left to conclude
+			if picked := readStateFile(common.CHERRY_PICK_HEAD_FILE_NAME); picked != "" {
+				fmt.Printf(pickIsEmpty, shortHash(picked))
+			} else if reverted := readStateFile(common.REVERT_HEAD_FILE_NAME); reverted != "" {
+				fmt.Printf(revertIsEmpty, shortHash(reverted))
+			} else {
+				fmt.Println(nothingToCommit)
+			}
+			clearPickState()
+			return
+		}
+		parents = append(parents, parentHash)
+	}
+	if mergeHead != "" {
+		parents = append(parents, mergeHead)
+	}
+
+	// A merge stopped by conflicts is concluded by the next commit, whose message starts from the merge's
+	message := cleanMessage(options.message)
+	if options.message == "" {
+		message = editCommitMessage(readStateFile(common.MERGE_MSG_FILE_NAME), treeEntriesOfCommit(parentHash), entries)
+	}
+	if message == "" {
+		fmt.Println(abortingEmptyMessage)
+		return
+	}
+
+	committer := newSignature(getConfig(common.USER_NAME_KEY))
+	author := committer
+	reflogMessage := "commit: "
+	if mergeHead != "" {
+		reflogMessage = "commit (merge): "
+	} else if parentHash == "" {
+		reflogMessage = "commit (initial): "
+	}
+
+	// A cherry-pick stopped by conflicts keeps the author of the commit it picked
+	if picked := readStateFile(common.CHERRY_PICK_HEAD_FILE_NAME); picked != "" {
+		pickedCommit, err := readCommit(picked)
+		if err != nil {
+			log.Fatal(err)
+		}
+		author = pickedCommit.author
+		reflogMessage = "commit (cherry-pick): "
+	}
+	if readStateFile(common.REVERT_HEAD_FILE_NAME) != "" {
+		reflogMessage = "commit (revert): "
+	}
+
+	commitHash := writeCommit(commitObject{
+		tree:      treeHash,
+		parents:   parents,
+		author:    author,
+		committer: committer,
+		message:   message,
+	})
+	updateHead(commitHash, reflogMessage+message)
+	writeIndex(entries)
+	removeStateFile(common.MERGE_HEAD_FILE_NAME)
+	clearPickState()
+
+	fmt.Println(changesCommited)
+}
+
+// amendCommit replaces HEAD with a commit of the index that has the same parents and author, keeping its message
+// unless a new one is given; the old tip stays in the reflog
+func amendCommit(options commitOptions, mergeHead string, entries []treeEntry) {
+	head := headCommit()
+	if head == "" {
+		fmt.Println(nothingToAmend)
+		return
+	}
+	if mergeHead != "" {
+		fmt.Println(cannotAmendDuringMerge)
+		return
+	}
+	headObject, err := readCommit(head)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	parent := ""
+	if len(headObject.parents) > 0 {
+		parent = headObject.parents[0]
+	}
+	message := cleanMessage(options.message)
+	if options.message == "" {
+		message = editCommitMessage(headObject.message, treeEntriesOfCommit(parent), entries)
+	}
+	if message == "" {
+		fmt.Println(abortingEmptyMessage)
+		return
+	}
+
+	hash := rewriteHeadCommit(entries, message, "commit (amend): "+message)
+	writeIndex(entries)
+	fmt.Printf(amendedCommit, shortHash(head), shortHash(hash))
+}
+
+// editCommitMessage opens $EDITOR on message followed by comments listing the staged changes and returns what
+// is left once the comments are stripped
+func editCommitMessage(message string, headEntries []treeEntry, entries []treeEntry) string {
+	path := vcsPath(commitEditMessageFile)
+	template := message + "\n" + fmt.Sprintf(commitTemplateHelp, headLabel()) + stagedChanges(headEntries, entries)
+	if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err := launchEditor(path); err != nil {
+		fmt.Printf(editorFailed, err)
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return stripComments(string(data))
+}
+
+// stagedChanges lists, as comment lines, the files entries add, change or remove compared to headEntries
+func stagedChanges(headEntries []treeEntry, entries []treeEntry) string {
+	before := entryHashes(headEntries)
+	after := entryHashes(entries)
+	var builder strings.Builder
+	for _, path := range sortedKeys(after) {
+		if hash, ok := before[path]; !ok {
+			builder.WriteString(commentPrefix + "\tnew file:   " + path + "\n")
+		} else if hash != after[path] {
+			builder.WriteString(commentPrefix + "\tmodified:   " + path + "\n")
+		}
+	}
+	for _, path := range sortedKeys(before) {
+		if _, ok := after[path]; !ok {
+			builder.WriteString(commentPrefix + "\tdeleted:    " + path + "\n")
+		}
+	}
+	return builder.String()
+}
+
+func logCase(consoleArgs []string) {
+	commitHash := headCommit()
+	if commitHash == "" {
+		fmt.Println(noCommitsYet)
+		return
+	}
+
+	// Follow first parents from HEAD so the newest commit is printed first
+	for commitHash != "" {
+		commit, err := readCommit(commitHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf(logMessage, commitHash, commit.author.name, commit.message)
+
+		commitHash = ""
+		if len(commit.parents) > 0 {
+			commitHash = commit.parents[0]
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+func TestParseCommitArgs(t *testing.T) {
+	messageFile := filepath.Join(t.TempDir(), "message")
+	if err := os.WriteFile(messageFile, []byte("from a file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		want       commitOptions
+		wantOutput string
+	}{
+		{name: "bare message", args: []string{"fix the bug"}, want: commitOptions{message: "fix the bug"}},
+		{name: "paragraphs", args: []string{"-m", "subject", "-m", "body"}, want: commitOptions{message: "subject\n\nbody"}},
+		{name: "file", args: []string{"-F", messageFile}, want: commitOptions{message: "from a file\n"}},
+		{name: "flags", args: []string{"-a", "--amend", "-m", "x"}, want: commitOptions{message: "x", amend: true, all: true}},
+		{name: "editor", args: nil, want: commitOptions{}},
+		{name: "unquoted words", args: []string{"fix", "the", "bug"}, wantOutput: "Unexpected argument 'the'"},
+		{name: "unknown option", args: []string{"--foo", "-m", "x"}, wantOutput: "Unknown option '--foo'"},
+		{name: "missing value", args: []string{"-m"}, wantOutput: "Option '-m' requires a value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var options commitOptions
+			var ok bool
+			output := captureOutput(t, func() { options, ok = parseCommitArgs(test.args) })
+			if test.wantOutput != "" {
+				if ok || !strings.Contains(output, test.wantOutput) {
+					t.Errorf("parseCommitArgs(%q) = %t printing %q, want a refusal with %q", test.args, ok, output, test.wantOutput)
+				}
+				return
+			}
+			if !ok || options != test.want {
+				t.Errorf("parseCommitArgs(%q) = %+v, %t, want %+v", test.args, options, ok, test.want)
+			}
+		})
+	}
+}
+
+// TestCommitRefusesStrayArguments checks nothing is committed when the message was not quoted
+func TestCommitRefusesStrayArguments(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commitFiles(t, "base", map[string]string{"f": "0\n"})
+	repo.writeFiles(t, map[string]string{"f": "1\n"})
+	runCommand(t, common.ADD, "f")
+
+	runCommand(t, common.COMMIT, "fix", "the", "bug")
+	if got := commitMessages(t); len(got) != 1 {
+		t.Fatalf("history = %q, want only the base commit", got)
+	}
+	runCommand(t, common.COMMIT, "fix the bug")
+	if got := commitMessages(t)[0]; got != "fix the bug" {
+		t.Errorf("HEAD is %q, want the quoted message", got)
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bufio"
+	"compress/zlib"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"version_control_go/common"
+)
+
+// readConfigFile parses "key=value" lines; a legacy line without "=" is the username
+func readConfigFile(path string) map[string]string {
+	config := map[string]string{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return config
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			config[common.USER_NAME_KEY] = line
+			continue
+		}
+		config[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return config
+}
+
+func writeConfigFile(path string, config map[string]string) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key + "=" + config[key] + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func getConfig(key string) string {
+	return readConfigFile(vcsPath(common.CONFIG_FILE_NAME))[key]
+}
+
+// compressionLevel is the zlib level from core.compression, read once per run
+var cachedCompressionLevel *int
+
+func compressionLevel() int {
+	if cachedCompressionLevel != nil {
+		return *cachedCompressionLevel
+	}
+
+	level := zlib.DefaultCompression
+	if value := getConfig(common.COMPRESSION_KEY); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err == nil && parsed >= zlib.HuffmanOnly && parsed <= zlib.BestCompression {
+			level = parsed
+		}
+	}
+	cachedCompressionLevel = &level
+	return level
+}
+
+func setConfig(key, value string) {
+	configFilePath := vcsPath(common.CONFIG_FILE_NAME)
+	config := readConfigFile(configFilePath)
+	config[key] = value
+	writeConfigFile(configFilePath, config)
+}
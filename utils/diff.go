@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"log"
+	"strings"
+)
+
+// diffHunk is a run of lines where a[aStart:aStart+aLength] was replaced by b[bStart:bStart+bLength]
+type diffHunk struct {
+	aStart, aLength int
+	bStart, bLength int
+}
+
+// splitLines splits content after every newline so joining the lines gives the content back
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns pairs of indexes of lines a and b have in common, using Myers' shortest edit script in its
+// linear space form
+func matchLines(a, b []string) [][2]int {
+	return appendMatches(nil, a, b, 0, 0)
+}
+
+// appendMatches adds the matches of a and b, which start at lines aStart and bStart of the whole files, by
+// splitting them around the middle snake of their edit script
+func appendMatches(matches [][2]int, a, b []string, aStart int, bStart int) [][2]int {
+	// Common prefix and suffix never need the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, [2]int{aStart + i, bStart + i})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(middleA) > 0 && len(middleB) > 0 {
+		x, y, u, v := middleSnake(middleA, middleB)
+		matches = appendMatches(matches, middleA[:x], middleB[:y], aStart+prefix, bStart+prefix)
+		for i := 0; i < u-x; i++ {
+			matches = append(matches, [2]int{aStart + prefix + x + i, bStart + prefix + y + i})
+		}
+		matches = appendMatches(matches, middleA[u:], middleB[v:], aStart+prefix+u, bStart+prefix+v)
+	}
+	for i := suffix; i > 0; i-- {
+		matches = append(matches, [2]int{aStart + len(a) - i, bStart + len(b) - i})
+	}
+	return matches
+}
+
+// middleSnake searches from both ends of a and b at once until the paths meet and returns the diagonal run
+// (x, y) to (u, v) where they do, which a shortest edit script passes through; a and b must differ in their first
+// and in their last lines
+func middleSnake(a, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// forward[k] is the furthest x reached on diagonal x-y=k from the start, backward[k] the same from the end
+	// counting lines back from n and m
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if reverseK := delta - k; odd && reverseK >= -(d-1) && reverseK <= d-1 && x+backward[offset+reverseK] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if forwardK := delta - k; !odd && forwardK >= -d && forwardK <= d && x+forward[offset+forwardK] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	log.Fatal("no middle snake")
+	return 0, 0, 0, 0
+}
+
+// diffLines returns the changed regions between a and b in order
+func diffLines(a, b []string) []diffHunk {
+	var hunks []diffHunk
+	aIndex, bIndex := 0, 0
+	for _, match := range append(matchLines(a, b), [2]int{len(a), len(b)}) {
+		if match[0] > aIndex || match[1] > bIndex {
+			hunks = append(hunks, diffHunk{
+				aStart: aIndex, aLength: match[0] - aIndex,
+				bStart: bIndex, bLength: match[1] - bIndex,
+			})
+		}
+		aIndex, bIndex = match[0]+1, match[1]+1
+	}
+	return hunks
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"version_control_go/common"
+)
+
+const commentPrefix = "#"
+
+// defaultEditor is what opens messages and hunks when $EDITOR is not set
+func defaultEditor() string {
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// launchEditor opens path in $EDITOR and waits for it to exit; the variable may hold arguments as well, split on
+// spaces like SVCS_SSH since no shell is involved
+func launchEditor(path string) error {
+	editor := strings.Fields(os.Getenv(common.EDITOR_ENV))
+	if len(editor) == 0 {
+		editor = []string{defaultEditor()}
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// stripComments drops the lines starting with "#" and the blank lines around what is left
+func stripComments(text string) string {
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, commentPrefix) {
+			kept = append(kept, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.Trim(strings.Join(kept, "\n"), "\n")
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"version_control_go/common"
+)
+
+const corruptObject = "error: %s is corrupt: %v\n"
+const hashMismatch = "error: %s %s does not match its content\n"
+const badObjectName = "error: %s is not a valid object name\n"
+const malformedObject = "error: %s %s is malformed: %v\n"
+const missingObject = "missing %s %s, referenced by %s %s\n"
+const wrongObjectType = "error: %s is a %s, referenced as a %s by %s %s\n"
+const badRef = "error: %s points at %s, which is not a commit\n"
+const badIndexEntry = "error: index entry '%s' points at %s, which is not a blob\n"
+const badIndexPath = "error: index entry '%s' is not a valid path\n"
+const duplicateIndexPath = "error: index has '%s' more than once\n"
+const unreachableObject = "unreachable %s %s\n"
+const danglingObject = "dangling %s %s\n"
+const fsckSummary = "Checked %d objects, %d problems found.\n"
+
+// objectLink is a reference from one object to another and the type the other must have
+type objectLink struct {
+	hash string
+	kind string
+}
+
+// fsckState collects what is learned about every stored object while checking the repository
+type fsckState struct {
+	types    map[string]string
+	links    map[string][]objectLink
+	problems int
+}
+
+func fsckCase(consoleArgs []string) {
+	showUnreachable := len(consoleArgs) > 2 && consoleArgs[2] == common.UNREACHABLE_FLAG
+
+	state := fsckState{types: map[string]string{}, links: map[string][]objectLink{}}
+	state.checkObjects()
+	state.checkLinks()
+	roots := state.checkRefs()
+	roots = append(roots, state.checkIndex()...)
+	state.reportUnreachable(roots, showUnreachable)
+
+	fmt.Printf(fsckSummary, len(state.types), state.problems)
+	if state.problems > 0 {
+		os.Exit(1)
+	}
+}
+
+func (s *fsckState) report(format string, args ...interface{}) {
+	fmt.Printf(format, args...)
+	s.problems++
+}
+
+// checkObjects rereads every loose and packed object, rehashes it and parses what it points at
+func (s *fsckState) checkObjects() {
+	looseHashes, _ := looseObjects()
+	packedHashes, _ := packedObjectsInfo()
+	hashes := append(looseHashes, packedHashes...)
+	sort.Strings(hashes)
+
+	for i, hash := range hashes {
+		if i > 0 && hashes[i-1] == hash {
+			continue
+		}
+		if !isHexHash(hash) {
+			s.report(badObjectName, hash)
+			continue
+		}
+
+		kind, data, err := readObjectWithType(hash)
+		if err != nil {
+			s.report(corruptObject, hash, err)
+			continue
+		}
+		s.types[hash] = kind
+		if hashObject(kind, data) != hash {
+			s.report(hashMismatch, kind, hash)
+			continue
+		}
+
+		switch kind {
+		case commitType:
+			commit, err := parseCommit(data)
+			if err != nil {
+				s.report(malformedObject, kind, hash, err)
+				continue
+			}
+			s.links[hash] = append(s.links[hash], objectLink{hash: commit.tree, kind: treeType})
+			for _, parent := range commit.parents {
+				s.links[hash] = append(s.links[hash], objectLink{hash: parent, kind: commitType})
+			}
+		case treeType:
+			entries, err := parseTree(data)
+			if err != nil {
+				s.report(malformedObject, kind, hash, err)
+				continue
+			}
+			for _, entry := range entries {
+				s.links[hash] = append(s.links[hash], objectLink{hash: entry.hash, kind: blobType})
+			}
+		}
+	}
+}
+
+// checkLinks makes sure every tree, parent and blob an object names is stored with the right type
+func (s *fsckState) checkLinks() {
+	for _, hash := range sortedKeys(s.links) {
+		for _, link := range s.links[hash] {
+			kind, exists := s.types[link.hash]
+			if !exists {
+				s.report(missingObject, link.kind, link.hash, s.types[hash], hash)
+			} else if kind != link.kind {
+				s.report(wrongObjectType, link.hash, kind, link.kind, s.types[hash], hash)
+			}
+		}
+	}
+}
+
+// checkRefs verifies branches, remote tracking branches, HEAD and MERGE_HEAD and returns the commits they and the
+// reflogs keep alive
+func (s *fsckState) checkRefs() []string {
+	refs := map[string]string{}
+	for _, prefix := range []string{common.HEADS_DIR, common.REMOTES_DIR} {
+		for name, hash := range listRefsIn(repoDir, prefix) {
+			refs[prefix+"/"+name] = hash
+		}
+	}
+
+	headRef, headHash := readHead()
+	if headRef == "" {
+		refs[common.HEAD_FILE_NAME] = headHash
+	} else if !strings.HasPrefix(headRef, common.HEADS_DIR+"/") || !isValidRefName(strings.TrimPrefix(headRef, common.HEADS_DIR+"/")) {
+		s.report(badRef, common.HEAD_FILE_NAME, headRef)
+	}
+	if mergeHead := readStateFile(common.MERGE_HEAD_FILE_NAME); mergeHead != "" {
+		refs[common.MERGE_HEAD_FILE_NAME] = mergeHead
+	}
+
+	var roots []string
+	for _, name := range sortedKeys(refs) {
+		hash := refs[name]
+		if s.types[hash] != commitType {
+			s.report(badRef, name, hash)
+			continue
+		}
+		roots = append(roots, hash)
+	}
+
+	// Where refs used to point keeps history alive too, old entries may name objects that are long gone
+	for _, hash := range reflogHashes() {
+		if s.types[hash] == commitType {
+			roots = append(roots, hash)
+		}
+	}
+	return roots
+}
+
+// checkIndex verifies every staged path is well formed, staged once and backed by a stored blob
+func (s *fsckState) checkIndex() []string {
+	if workTreeDir == "" {
+		return nil
+	}
+
+	var roots []string
+	seen := map[string]bool{}
+	for _, entry := range readIndex() {
+		if seen[entry.path] {
+			s.report(duplicateIndexPath, entry.path)
+		}
+		seen[entry.path] = true
+
+		if entry.path == "" || path.IsAbs(entry.path) || path.Clean(entry.path) != entry.path ||
+			entry.path == ".." || strings.HasPrefix(entry.path, "../") {
+			s.report(badIndexPath, entry.path)
+		}
+
+		// Entries from older repositories are staged from the work tree at the next commit
+		if entry.hash == "" {
+			continue
+		}
+		if s.types[entry.hash] != blobType {
+			s.report(badIndexEntry, entry.path, entry.hash)
+			continue
+		}
+		roots = append(roots, entry.hash)
+	}
+	return roots
+}
+
+// reportUnreachable lists the dangling objects, the unreachable ones nothing else refers to, or every
+// unreachable object with --unreachable; neither counts as a problem
+func (s *fsckState) reportUnreachable(roots []string, showUnreachable bool) {
+	reachable := map[string]bool{}
+	queue := roots
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+		for _, link := range s.links[hash] {
+			queue = append(queue, link.hash)
+		}
+	}
+
+	referenced := map[string]bool{}
+	for _, links := range s.links {
+		for _, link := range links {
+			referenced[link.hash] = true
+		}
+	}
+
+	for _, hash := range sortedKeys(s.types) {
+		if reachable[hash] {
+			continue
+		}
+		if showUnreachable {
+			fmt.Printf(unreachableObject, s.types[hash], hash)
+		} else if !referenced[hash] {
+			fmt.Printf(danglingObject, s.types[hash], hash)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
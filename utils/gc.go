@@ -0,0 +1,297 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+	"version_control_go/common"
+)
+
+const nothingToPack = "Nothing to pack."
+const packedObjects = "Packed %d objects (%d deltas) into %s.\n"
+
+// maxDeltaDepth bounds how many deltas must be applied to read an object back
+const maxDeltaDepth = 10
+
+// gcCase packs every reachable object and prunes unreachable ones older than --expire; unreachable objects
+// still in their grace period are left loose, keeping the age of the pack they came from
+func gcCase(consoleArgs []string) {
+	expire, ok := expiryOption(consoleArgs)
+	if !ok {
+		return
+	}
+
+	sizeBefore := objectStoreSize()
+	looseHashes, _ := looseObjects()
+	packedHashes, _ := packedObjectsInfo()
+	reachable := reachableObjectSet()
+	removed, _ := pruneLooseObjects(looseHashes, reachable, expire)
+
+	packed := map[string]bool{}
+	var looseToPack, unreachablePacked []string
+	for _, hash := range looseHashes {
+		if reachable[hash] {
+			looseToPack = append(looseToPack, hash)
+			packed[hash] = true
+		}
+	}
+	for _, hash := range packedHashes {
+		if reachable[hash] {
+			packed[hash] = true
+		} else {
+			unreachablePacked = append(unreachablePacked, hash)
+		}
+	}
+
+	oldPackIndexes := packIndexPaths()
+	if len(looseToPack) == 0 && len(unreachablePacked) == 0 && len(oldPackIndexes) < 2 {
+		fmt.Println(nothingToPack)
+		if removed == 0 {
+			return
+		}
+	} else {
+		removed += loosenUnreachable(unreachablePacked, expire)
+		packReachable(packed, looseToPack, oldPackIndexes)
+	}
+	removeEmptyObjectDirs()
+
+	// What was rewritten into the new pack or loosened again was not reclaimed, only the net change is
+	fmt.Printf(prunedObjects, removed, sizeBefore-objectStoreSize())
+}
+
+// loosenUnreachable stores the unreachable packed objects that are still in their grace period as loose objects
+// dated like their pack, so the packs can be removed; it returns how many were old enough to drop instead
+func loosenUnreachable(hashes []string, expire time.Time) int {
+	dropped := 0
+	for _, hash := range hashes {
+		packPath, _, err := findPackedObject(hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		info, err := os.Stat(packPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !info.ModTime().After(expire) {
+			dropped++
+			continue
+		}
+
+		kind, data, err := readPackedObject(hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !fileExists(objectPath(hash)) {
+			writeLooseObject(hash, kind, data)
+			os.Chtimes(objectPath(hash), info.ModTime(), info.ModTime())
+		}
+	}
+	return dropped
+}
+
+// packReachable writes the reachable objects into one new pack and removes their loose copies and the old packs
+func packReachable(packed map[string]bool, looseToPack []string, oldPackIndexes []string) {
+	packPath := ""
+	if len(packed) > 0 {
+		hashes := make([]string, 0, len(packed))
+		for hash := range packed {
+			hashes = append(hashes, hash)
+		}
+		bases := map[string]string{}
+		for target, base := range deltaBases() {
+			if packed[target] && packed[base] {
+				bases[target] = base
+			}
+		}
+
+		var deltas int
+		var err error
+		packPath, deltas, err = writePack(hashes, bases)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf(packedObjects, len(hashes), deltas, filepath.Base(packPath))
+	}
+
+	for _, hash := range looseToPack {
+		removeFile(objectPath(hash))
+	}
+	for _, indexPath := range oldPackIndexes {
+		if packPathOfIndex(indexPath) == packPath {
+			continue
+		}
+		removeFile(indexPath)
+		removeFile(packPathOfIndex(indexPath))
+	}
+}
+
+// objectStoreSize is the space loose objects and packs take together
+func objectStoreSize() int64 {
+	_, looseSize := looseObjects()
+	_, packedSize := packedObjectsInfo()
+	return looseSize + packedSize
+}
+
+// looseObjects returns the hashes of objects stored one per file and their total size
+func looseObjects() ([]string, int64) {
+	var hashes []string
+	var size int64
+
+	objectsDir := vcsPath(common.OBJECTS_DIR_NAME)
+	err := filepath.WalkDir(objectsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == common.PACK_DIR_NAME {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, filepath.Base(filepath.Dir(path))+entry.Name())
+		size += info.Size()
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	return hashes, size
+}
+
+// packedObjectsInfo returns the hashes stored in packs and the size of the pack files
+func packedObjectsInfo() ([]string, int64) {
+	seen := map[string]bool{}
+	var hashes []string
+	var size int64
+	for _, indexPath := range packIndexPaths() {
+		index, err := openPackIndex(indexPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		all, err := index.hashesWithPrefix("")
+		index.close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, hash := range all {
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+
+		for _, path := range []string{indexPath, packPathOfIndex(indexPath)} {
+			if info, err := os.Stat(path); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return hashes, size
+}
+
+// deltaBases pairs each blob with the next newer version of the same path, walking history from every ref
+func deltaBases() map[string]string {
+	versions := map[string][]string{}
+	listed := map[string]map[string]bool{}
+	seenTrees := map[string]bool{}
+
+	tips := append(localRefTips(), headCommit())
+	seenCommits := map[string]bool{}
+	queue := tips
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if hash == "" || seenCommits[hash] {
+			continue
+		}
+		seenCommits[hash] = true
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, commit.parents...)
+		if seenTrees[commit.tree] {
+			continue
+		}
+		seenTrees[commit.tree] = true
+
+		entries, err := readTree(commit.tree)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if listed[entry.path] == nil {
+				listed[entry.path] = map[string]bool{}
+			}
+			if !listed[entry.path][entry.hash] {
+				listed[entry.path][entry.hash] = true
+				versions[entry.path] = append(versions[entry.path], entry.hash)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(versions))
+	for path := range versions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	bases := map[string]string{}
+	for _, path := range paths {
+		for i := 1; i < len(versions[path]); i++ {
+			target, base := versions[path][i], versions[path][i-1]
+			if _, ok := bases[target]; ok {
+				continue
+			}
+
+			// The same blob can appear under several paths, so make sure the chain never loops back
+			depth := 1
+			loops := false
+			for current, ok := bases[base]; ok; current, ok = bases[current] {
+				if current == target {
+					loops = true
+					break
+				}
+				depth++
+			}
+			if base != target && !loops && depth < maxDeltaDepth {
+				bases[target] = base
+			}
+		}
+	}
+	return bases
+}
+
+func removeFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+}
+
+// removeEmptyObjectDirs drops the two character fan out directories left empty after packing
+func removeEmptyObjectDirs() {
+	entries, err := os.ReadDir(vcsPath(common.OBJECTS_DIR_NAME))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == common.PACK_DIR_NAME {
+			continue
+		}
+		dir := vcsPath(common.OBJECTS_DIR_NAME, entry.Name())
+		if children, err := os.ReadDir(dir); err == nil && len(children) == 0 {
+			os.Remove(dir)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"version_control_go/common"
+
+	"lukechampine.com/blake3"
+)
+
+// Hash algorithms a repository can name its objects with, recorded under core.hash when it is created
+const (
+	sha256HashName = "sha256"
+	blake3HashName = "blake3"
+)
+
+const defaultHashName = sha256HashName
+
+// Hasher names an object after its type and content
+type Hasher interface {
+	Name() string
+	Sum(kind string, data []byte) string
+}
+
+// objectHeader is hashed in front of the content so a blob can never share a name with a tree or commit
+func objectHeader(kind string, data []byte) []byte {
+	return []byte(fmt.Sprintf("%s %d\x00", kind, len(data)))
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string {
+	return sha256HashName
+}
+
+func (sha256Hasher) Sum(kind string, data []byte) string {
+	hash := sha256.New()
+	hash.Write(objectHeader(kind, data))
+	hash.Write(data)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+type blake3Hasher struct{}
+
+func (blake3Hasher) Name() string {
+	return blake3HashName
+}
+
+func (blake3Hasher) Sum(kind string, data []byte) string {
+	hash := blake3.New(32, nil)
+	hash.Write(objectHeader(kind, data))
+	hash.Write(data)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func hasherByName(name string) (Hasher, error) {
+	switch name {
+	case sha256HashName:
+		return sha256Hasher{}, nil
+	case blake3HashName:
+		return blake3Hasher{}, nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm '%s'", name)
+}
+
+// hashNameIn returns the algorithm the repository in dir names its objects with; init records it, a repository
+// without it uses the default. Reading it never writes, dir may be a remote the user can't write to
+func hashNameIn(dir string) string {
+	if name := readConfigFile(filepath.Join(dir, common.CONFIG_FILE_NAME))[common.HASH_KEY]; name != "" {
+		return name
+	}
+	return defaultHashName
+}
+
+// hasObjectsIn tells whether the repository in dir stores any object, loose or packed; only until then can its
+// hash algorithm be chosen
+func hasObjectsIn(dir string) bool {
+	objectDirs, err := os.ReadDir(filepath.Join(dir, common.OBJECTS_DIR_NAME))
+	if err != nil {
+		return false
+	}
+	for _, objectDir := range objectDirs {
+		if !objectDir.IsDir() {
+			continue
+		}
+		if entries, err := os.ReadDir(filepath.Join(dir, common.OBJECTS_DIR_NAME, objectDir.Name())); err == nil && len(entries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// repoHasher is the Hasher of the open repository, read once per run
+var cachedHasher Hasher
+
+func repoHasher() Hasher {
+	if cachedHasher != nil {
+		return cachedHasher
+	}
+
+	hasher, err := hasherByName(hashNameIn(repoDir))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cachedHasher = hasher
+	return hasher
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"version_control_go/common"
+)
+
+func TestHashNameIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{name: "recorded", config: common.HASH_KEY + "=" + blake3HashName + "\n", want: blake3HashName},
+		{name: "not recorded", config: common.BARE_KEY + "=true\n", want: defaultHashName},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, common.CONFIG_FILE_NAME)
+			if err := os.WriteFile(configPath, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// A remote can be read only, reading its algorithm must not write to it
+			if err := os.Chmod(dir, 0555); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chmod(dir, 0755)
+			if err := os.Chmod(configPath, 0444); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hashNameIn(dir); got != test.want {
+				t.Errorf("hashNameIn() = %s, want %s", got, test.want)
+			}
+			if data, _ := os.ReadFile(configPath); string(data) != test.config {
+				t.Errorf("hashNameIn() rewrote the config to %q", data)
+			}
+		})
+	}
+}
+
+func TestHasherByNameRefusesUnknown(t *testing.T) {
+	for _, name := range []string{"legacy", "md5", ""} {
+		if _, err := hasherByName(name); err == nil {
+			t.Errorf("hasherByName(%q) found a hasher", name)
+		}
+	}
+}
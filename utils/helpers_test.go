@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// testRepo is a repository made for one test in its own temporary directory; workTree is "" when it is bare
+type testRepo struct {
+	dir      string
+	workTree string
+}
+
+// newTestRepo creates a repository with a work tree and opens it
+func newTestRepo(t *testing.T) testRepo {
+	t.Helper()
+	workTree := t.TempDir()
+	repo := testRepo{dir: filepath.Join(workTree, common.VCS_DIR_NAME), workTree: workTree}
+	initRepoDir(repo.dir, false, "")
+	repo.open(t)
+	setConfig(common.USER_NAME_KEY, "tester")
+	return repo
+}
+
+// newBareTestRepo creates a bare repository without opening it
+func newBareTestRepo(t *testing.T) testRepo {
+	t.Helper()
+	repo := testRepo{dir: t.TempDir()}
+	initRepoDir(repo.dir, true, "")
+	return repo
+}
+
+// open makes the repository the one commands work on, as openRepository does for the command line
+func (repo testRepo) open(t *testing.T) {
+	t.Helper()
+	repoDir, workTreeDir, cachedHasher = repo.dir, repo.workTree, nil
+
+	dir := repo.workTree
+	if dir == "" {
+		dir = repo.dir
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+// use makes the repository the open one until the returned function puts the previous one back; unlike open it
+// leaves the working directory alone, so a server can use it for the length of one request
+func (repo testRepo) use() func() {
+	savedRepoDir, savedWorkTreeDir, savedHasher := repoDir, workTreeDir, cachedHasher
+	repoDir, workTreeDir, cachedHasher = repo.dir, repo.workTree, nil
+	return func() {
+		repoDir, workTreeDir, cachedHasher = savedRepoDir, savedWorkTreeDir, savedHasher
+	}
+}
+
+// writeFiles writes the files, given by their slash separated paths, into the work tree
+func (repo testRepo) writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(repo.workTree, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// commitFiles writes the files, stages everything and commits, returning the new HEAD
+func (repo testRepo) commitFiles(t *testing.T, message string, files map[string]string) string {
+	t.Helper()
+	repo.writeFiles(t, files)
+	runCommand(t, common.ADD, common.ADD_ALL_FLAG)
+	runCommand(t, common.COMMIT, common.MESSAGE_FLAG, message)
+	return headCommit()
+}
+
+// readWorkTreeFile returns the content of a work tree file, "" when it doesn't exist
+func (repo testRepo) readWorkTreeFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(repo.workTree, filepath.FromSlash(name)))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// runCommand runs a command on the open repository the way the command line does and returns what it printed
+func runCommand(t *testing.T, command string, args ...string) string {
+	t.Helper()
+	return captureOutput(t, func() {
+		CommandSwitchCases(command, common.Commands[command], append([]string{"svcs", command}, args...))
+	})
+}
+
+// captureOutput returns what fn printed to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	fn()
+	os.Stdout = stdout
+	writer.Close()
+	defer reader.Close()
+	return <-output
+}
+
+// commitMessages lists the first lines of the messages from HEAD down its first parents
+func commitMessages(t *testing.T) []string {
+	t.Helper()
+	var subjects []string
+	for hash := headCommit(); hash != ""; {
+		commit, err := readCommit(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, commitSubject(commit))
+		hash = ""
+		if len(commit.parents) > 0 {
+			hash = commit.parents[0]
+		}
+	}
+	return subjects
+}
+
+// lines turns space separated words into the lines of a file, one word per line
+func lines(text string) []string {
+	return splitLines(strings.Join(strings.Fields(text), "\n") + "\n")
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"version_control_go/common"
+)
+
+const refsPath = "/svcs/refs"
+const fetchPath = "/svcs/fetch"
+const pushPath = "/svcs/push"
+const protocolContentType = "application/x-svcs"
+
+const serveUsage = "Usage: serve --http <address> [--allow-push] | serve --stdio"
+const servingRepository = "Serving %s on http://%s\n"
+const servingPushes = "Anyone who can reach %s can push to any branch, force pushes included.\n"
+const pushDisabled = "push is disabled, the server was started without --allow-push"
+
+// Request bodies are read whole, a fetch request only names commits while a push carries objects
+const maxFetchRequestSize = 16 << 20
+const maxPushRequestSize = 1 << 30
+
+// httpTransport reaches a repository exposed by "serve --http"
+type httpTransport struct {
+	baseURL string
+}
+
+func (t httpTransport) post(path string, body io.Reader) (*http.Response, error) {
+	response, err := http.Post(t.baseURL+path, protocolContentType, body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(response.Body)
+		return nil, pushErrorFromMessage(string(message))
+	}
+	return response, nil
+}
+
+func (t httpTransport) advertisedRefs() (refAdvertisement, error) {
+	response, err := http.Get(t.baseURL + refsPath)
+	if err != nil {
+		return refAdvertisement{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return refAdvertisement{}, fmt.Errorf("%s: %s", t.baseURL, response.Status)
+	}
+	return readRefAdvertisement(bufio.NewReader(response.Body))
+}
+
+func (t httpTransport) fetchObjects(wants []string, haves []string) error {
+	var request bytes.Buffer
+	if err := writeFetchRequest(&request, wants, haves); err != nil {
+		return err
+	}
+
+	response, err := t.post(fetchPath, &request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = readObjectStream(bufio.NewReader(response.Body))
+	return err
+}
+
+func (t httpTransport) pushBranch(branch string, oldHash string, newHash string, force bool, haves []string) error {
+	var request bytes.Buffer
+	if err := writePushRequest(&request, branch, oldHash, newHash, force, haves); err != nil {
+		return err
+	}
+
+	response, err := t.post(pushPath, &request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// repositoryHandler serves the opened repository; it is what "serve --http" listens with. Nothing authenticates
+// the client, so pushes are only accepted with allowPush
+func repositoryHandler(allowPush bool) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(refsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, errMalformedRequest.Error(), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", protocolContentType)
+		if err := writeRefAdvertisement(w); err != nil {
+			log.Println(err)
+		}
+	})
+
+	mux.HandleFunc(fetchPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, errMalformedRequest.Error(), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The stream is built before anything is sent so a failure can still become an error status
+		var response bytes.Buffer
+		body := http.MaxBytesReader(w, r.Body, maxFetchRequestSize)
+		if err := serveFetch(bufio.NewReader(body), &response); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", protocolContentType)
+		if _, err := response.WriteTo(w); err != nil {
+			log.Println(err)
+		}
+	})
+
+	mux.HandleFunc(pushPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, errMalformedRequest.Error(), http.StatusMethodNotAllowed)
+			return
+		}
+		if !allowPush {
+			http.Error(w, pushDisabled, http.StatusForbidden)
+			return
+		}
+		body := http.MaxBytesReader(w, r.Body, maxPushRequestSize)
+		if err := servePush(bufio.NewReader(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	return mux
+}
+
+func serveCase(consoleArgs []string) {
+	if len(consoleArgs) > 2 && consoleArgs[2] == common.STDIO_FLAG {
+		if err := serveStdio(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(consoleArgs) < 4 || consoleArgs[2] != common.HTTP_FLAG {
+		fmt.Println(serveUsage)
+		return
+	}
+	address := consoleArgs[3]
+	allowPush := false
+	for _, arg := range consoleArgs[4:] {
+		if arg != common.ALLOW_PUSH_FLAG {
+			fmt.Println(serveUsage)
+			return
+		}
+		allowPush = true
+	}
+
+	fmt.Printf(servingRepository, repoDir, address)
+	if allowPush {
+		fmt.Printf(servingPushes, address)
+	}
+	log.Fatal(http.ListenAndServe(address, repositoryHandler(allowPush)))
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"version_control_go/common"
+)
+
+// readIndex returns the staged entries; lines from older repositories only hold a path and have no hash yet
+func readIndex() []treeEntry {
+	file, err := os.Open(vcsPath(common.INDEX_FILE_NAME))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var entries []treeEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		hash, path, found := strings.Cut(line, " ")
+		if !found {
+			entries = append(entries, treeEntry{path: line})
+			continue
+		}
+		entries = append(entries, treeEntry{hash: hash, path: path})
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return entries
+}
+
+func writeIndex(entries []treeEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	var builder strings.Builder
+	for _, entry := range entries {
+		builder.WriteString(entry.hash + " " + entry.path + "\n")
+	}
+
+	if err := os.WriteFile(vcsPath(common.INDEX_FILE_NAME), []byte(builder.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stageEntry replaces or adds the index entry for path
+func stageEntry(entries []treeEntry, path string, hash string) []treeEntry {
+	for i := range entries {
+		if entries[i].path == path {
+			entries[i].hash = hash
+			return entries
+		}
+	}
+	return append(entries, treeEntry{hash: hash, path: path})
+}
+
+// unstageEntry removes the index entry for path
+func unstageEntry(entries []treeEntry, path string) []treeEntry {
+	for i := range entries {
+		if entries[i].path == path {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+// fillLegacyIndexEntries stages the work tree content of entries that were tracked without a hash
+func fillLegacyIndexEntries(entries []treeEntry) []treeEntry {
+	for i := range entries {
+		if entries[i].hash != "" {
+			continue
+		}
+		hash, err := writeBlobFromFile(workTreeFile(entries[i].path))
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries[i].hash = hash
+	}
+	return entries
+}
+
+// stageTrackedChanges restages the tracked files whose work tree content differs from the index and drops the
+// ones deleted from the work tree
+func stageTrackedChanges(entries []treeEntry) []treeEntry {
+	var staged []treeEntry
+	for _, entry := range entries {
+		data, err := os.ReadFile(workTreeFile(entry.path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if hashBlob(data) != entry.hash {
+			entry.hash = writeBlob(data)
+		}
+		staged = append(staged, entry)
+	}
+	return staged
+}
+
+// workTreeFile turns an index path into a path on disk
+func workTreeFile(path string) string {
+	return filepath.Join(workTreeDir, filepath.FromSlash(path))
+}
@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"version_control_go/common"
+)
+
+const alreadyUpToDate = "Already up to date."
+const fastForward = "Fast-forward"
+const mergeMade = "Merge made by the three-way strategy."
+const mergeConflict = "CONFLICT (%s): Merge conflict in %s\n"
+const automaticMergeFailed = "Automatic merge failed; fix conflicts and then commit the result."
+const unmergedPaths = "%s is not possible because you have unmerged files:\n%sFix them up in the work tree, then use 'add <path>' to mark the resolution.\n"
+
+const conflictOursMarker = "<<<<<<< "
+const conflictSeparator = "=======\n"
+const conflictTheirsMarker = ">>>>>>> "
+
+// mergeResult is the outcome of merging two trees: the entries to stage and the files left with conflicts
+type mergeResult struct {
+	entries   []treeEntry
+	conflicts map[string][]byte
+}
+
+func isAncestor(ancestor string, descendant string) bool {
+	return ancestorsOf(descendant)[ancestor]
+}
+
+// ancestorsOf returns every commit reachable from hash, including hash itself
+func ancestorsOf(hash string) map[string]bool {
+	seen := map[string]bool{}
+	queue := []string{hash}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == "" || seen[current] {
+			continue
+		}
+		seen[current] = true
+
+		commit, err := readCommit(current)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, commit.parents...)
+	}
+	return seen
+}
+
+// mergeBase returns the nearest commit reachable from both a and b, "" if histories are unrelated
+func mergeBase(a string, b string) string {
+	ancestors := ancestorsOf(a)
+	seen := map[string]bool{}
+	queue := []string{b}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == "" || seen[current] {
+			continue
+		}
+		if ancestors[current] {
+			return current
+		}
+		seen[current] = true
+
+		commit, err := readCommit(current)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, commit.parents...)
+	}
+	return ""
+}
+
+// merge3Lines merges the changes base->ours and base->theirs, marking overlapping changes as conflicts
+func merge3Lines(base, ours, theirs []string, oursLabel, theirsLabel string) ([]string, bool) {
+	oursMatch := baseMatchIndexes(base, ours)
+	theirsMatch := baseMatchIndexes(base, theirs)
+
+	var merged []string
+	conflict := false
+	i, o, t := 0, 0, 0
+	for {
+		// The next base line both sides kept is where they are in sync again
+		j := i
+		for j < len(base) && (oursMatch[j] < 0 || theirsMatch[j] < 0) {
+			j++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if j < len(base) {
+			oEnd, tEnd = oursMatch[j], theirsMatch[j]
+		}
+
+		baseChunk, oursChunk, theirsChunk := base[i:j], ours[o:oEnd], theirs[t:tEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case equalLines(theirsChunk, baseChunk), equalLines(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			conflict = true
+			merged = append(merged, conflictOursMarker+oursLabel+"\n")
+			merged = append(merged, withTrailingNewline(oursChunk)...)
+			merged = append(merged, conflictSeparator)
+			merged = append(merged, withTrailingNewline(theirsChunk)...)
+			merged = append(merged, conflictTheirsMarker+theirsLabel+"\n")
+		}
+
+		if j >= len(base) {
+			break
+		}
+		merged = append(merged, base[j])
+		i, o, t = j+1, oEnd+1, tEnd+1
+	}
+	return merged, conflict
+}
+
+// baseMatchIndexes maps every base line to the line of other it was kept as, or -1
+func baseMatchIndexes(base, other []string) []int {
+	indexes := make([]int, len(base))
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for _, match := range matchLines(base, other) {
+		indexes[match[0]] = match[1]
+	}
+	return indexes
+}
+
+// withTrailingNewline keeps conflict markers on their own line when a chunk ends the file without a newline
+func withTrailingNewline(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	fixed := append([]string(nil), lines...)
+	fixed[len(fixed)-1] += "\n"
+	return fixed
+}
+
+// mergeTrees applies the changes between base and theirs on top of ours file by file
+func mergeTrees(base, ours, theirs []treeEntry, oursLabel, theirsLabel string) mergeResult {
+	baseHashes, oursHashes, theirsHashes := entryHashes(base), entryHashes(ours), entryHashes(theirs)
+
+	seen := map[string]bool{}
+	var paths []string
+	for _, hashes := range []map[string]string{baseHashes, oursHashes, theirsHashes} {
+		for path := range hashes {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	result := mergeResult{conflicts: map[string][]byte{}}
+	for _, path := range paths {
+		baseHash, oursHash, theirsHash := baseHashes[path], oursHashes[path], theirsHashes[path]
+
+		switch {
+		case oursHash == theirsHash, theirsHash == baseHash:
+			if oursHash != "" {
+				result.entries = append(result.entries, treeEntry{hash: oursHash, path: path})
+			}
+		case oursHash == baseHash:
+			if theirsHash != "" {
+				result.entries = append(result.entries, treeEntry{hash: theirsHash, path: path})
+			}
+		case oursHash == "" || theirsHash == "":
+			// Deleted on one side and modified on the other, the modified version stays for review
+			kept := oursHash
+			if kept == "" {
+				kept = theirsHash
+			}
+			result.entries = append(result.entries, treeEntry{hash: kept, path: path})
+			result.conflicts[path] = readBlobOrEmpty(kept)
+			fmt.Printf(mergeConflict, "modify/delete", path)
+		default:
+			merged, conflict := merge3Lines(
+				splitLines(string(readBlobOrEmpty(baseHash))),
+				splitLines(string(readBlobOrEmpty(oursHash))),
+				splitLines(string(readBlobOrEmpty(theirsHash))),
+				oursLabel, theirsLabel,
+			)
+			content := []byte(strings.Join(merged, ""))
+			if conflict {
+				// Ours stays staged until the user resolves the file and adds it
+				result.entries = append(result.entries, treeEntry{hash: oursHash, path: path})
+				result.conflicts[path] = content
+				fmt.Printf(mergeConflict, "content", path)
+				continue
+			}
+			result.entries = append(result.entries, treeEntry{hash: writeBlob(content), path: path})
+		}
+	}
+	return result
+}
+
+func entryHashes(entries []treeEntry) map[string]string {
+	hashes := map[string]string{}
+	for _, entry := range entries {
+		hashes[entry.path] = entry.hash
+	}
+	return hashes
+}
+
+func readBlobOrEmpty(hash string) []byte {
+	if hash == "" {
+		return nil
+	}
+	data, err := readObject(hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// applyMergeResult checks out the merged tree and overwrites conflicting files with their marked content
+func applyMergeResult(result mergeResult) {
+	if refuseUntrackedOverwrite(append(treePaths(result.entries), sortedKeys(result.conflicts)...)) {
+		os.Exit(1)
+	}
+	checkoutTree(result.entries)
+	for path, content := range result.conflicts {
+		if err := os.WriteFile(workTreeFile(path), content, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writeConflicts(sortedKeys(result.conflicts))
+}
+
+// readConflicts lists the paths whose conflicts have not been marked resolved with 'add' yet
+func readConflicts() []string {
+	content := readStateFile(common.CONFLICTS_FILE_NAME)
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
+
+func writeConflicts(paths []string) {
+	if len(paths) == 0 {
+		removeStateFile(common.CONFLICTS_FILE_NAME)
+		return
+	}
+	writeStateFile(common.CONFLICTS_FILE_NAME, strings.Join(paths, "\n"))
+}
+
+// resolveConflicts marks paths as resolved once they are staged
+func resolveConflicts(paths ...string) {
+	conflicts := readConflicts()
+	if len(conflicts) == 0 {
+		return
+	}
+	resolved := map[string]bool{}
+	for _, path := range paths {
+		resolved[path] = true
+	}
+	var left []string
+	for _, path := range conflicts {
+		if !resolved[path] {
+			left = append(left, path)
+		}
+	}
+	writeConflicts(left)
+}
+
+// refuseUnmergedPaths lists the unresolved conflicts, if any, and reports whether there were any
+func refuseUnmergedPaths(action string) bool {
+	conflicts := readConflicts()
+	if len(conflicts) == 0 {
+		return false
+	}
+	fmt.Printf(unmergedPaths, action, "\t"+strings.Join(conflicts, "\n\t")+"\n")
+	return true
+}
+
+// mergeCommit merges theirs into HEAD, committing unless there are conflicts to resolve
+func mergeCommit(theirs string, theirsLabel string, message string) {
+	ours := headCommit()
+	if ours != "" && isAncestor(theirs, ours) {
+		fmt.Println(alreadyUpToDate)
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+	if ours == "" || isAncestor(ours, theirs) {
+		checkoutCommit(theirs)
+		updateHead(theirs, "merge "+theirsLabel+": Fast-forward")
+		fmt.Println(fastForward)
+		return
+	}
+
+	base := mergeBase(ours, theirs)
+	result := mergeTrees(treeEntriesOfCommit(base), treeEntriesOfCommit(ours), treeEntriesOfCommit(theirs), common.HEAD_FILE_NAME, theirsLabel)
+	applyMergeResult(result)
+
+	if len(result.conflicts) > 0 {
+		// commit picks the second parent up from MERGE_HEAD once conflicts are resolved
+		writeStateFile(common.MERGE_HEAD_FILE_NAME, theirs)
+		writeStateFile(common.MERGE_MSG_FILE_NAME, message)
+		fmt.Println(automaticMergeFailed)
+		return
+	}
+
+	author := newSignature(getConfig(common.USER_NAME_KEY))
+	commitHash := writeCommit(commitObject{
+		tree:      writeTree(result.entries),
+		parents:   []string{ours, theirs},
+		author:    author,
+		committer: author,
+		message:   message,
+	})
+	updateHead(commitHash, "merge "+theirsLabel+": Merge made by the 'three-way' strategy.")
+	fmt.Println(mergeMade)
+}
+
+// readStateFile returns the trimmed content of a file like MERGE_HEAD, "" if there is none
+func readStateFile(name string) string {
+	data, err := os.ReadFile(vcsPath(name))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func writeStateFile(name string, content string) {
+	if err := os.WriteFile(vcsPath(name), []byte(content+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func removeStateFile(name string) {
+	if err := os.Remove(vcsPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []diffHunk
+	}{
+		{"equal", "a b c", "a b c", nil},
+		{"insert", "a c", "a b c", []diffHunk{{aStart: 1, aLength: 0, bStart: 1, bLength: 1}}},
+		{"delete", "a b c", "a c", []diffHunk{{aStart: 1, aLength: 1, bStart: 1, bLength: 0}}},
+		{"replace", "a b c", "a x c", []diffHunk{{aStart: 1, aLength: 1, bStart: 1, bLength: 1}}},
+		{"from nothing", "", "a b", []diffHunk{{aStart: 0, aLength: 0, bStart: 0, bLength: 2}}},
+		{"two changes", "a b c d e", "x b c d y", []diffHunk{
+			{aStart: 0, aLength: 1, bStart: 0, bLength: 1},
+			{aStart: 4, aLength: 1, bStart: 4, bLength: 1},
+		}},
+		{"moved line", "a b c d", "b c d a", []diffHunk{
+			{aStart: 0, aLength: 1, bStart: 0, bLength: 0},
+			{aStart: 4, aLength: 0, bStart: 3, bLength: 1},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := lines(test.a), lines(test.b)
+			if test.a == "" {
+				a = nil
+			}
+			got := diffLines(a, b)
+			if len(got) != len(test.want) {
+				t.Fatalf("diffLines() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("diffLines() = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+// TestMatchLinesIsLongest checks the matches against the longest common subsequence, which a shortest edit
+// script always keeps
+func TestMatchLinesIsLongest(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"a b c a b b a", "c b a b a c"},
+		{"a a a a", "a a"},
+		{"x y z", "a b c"},
+		{"a b c d e f g", "g f e d c b a"},
+		{"a b a b a b", "b a b a b a"},
+		{"a", "b a b"},
+	}
+	for _, test := range tests {
+		a, b := lines(test.a), lines(test.b)
+		matches := matchLines(a, b)
+		for i, match := range matches {
+			if a[match[0]] != b[match[1]] || i > 0 && (match[0] <= matches[i-1][0] || match[1] <= matches[i-1][1]) {
+				t.Fatalf("matchLines(%q, %q) = %v is not a common subsequence", test.a, test.b, matches)
+			}
+		}
+		if want := longestCommonSubsequence(a, b); len(matches) != want {
+			t.Errorf("matchLines(%q, %q) kept %d lines, want %d", test.a, test.b, len(matches), want)
+		}
+	}
+}
+
+func longestCommonSubsequence(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = maxInt(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestMerge3Lines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantConflict       bool
+	}{
+		{"only ours changed", "a b c", "a x c", "a b c", "a x c", false},
+		{"only theirs changed", "a b c", "a b c", "a b y", "a b y", false},
+		{"both changed apart", "a b c d e", "x b c d e", "a b c d y", "x b c d y", false},
+		{"both made the same change", "a b c", "a x c", "a x c", "a x c", false},
+		{"overlapping changes", "a b c", "a x c", "a y c", "a <<<<<<< ours x ======= y >>>>>>> theirs c", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflict := merge3Lines(lines(test.base), lines(test.ours), lines(test.theirs), "ours", "theirs")
+			if got := strings.Join(strings.Fields(strings.Join(merged, "")), " "); got != test.want {
+				t.Errorf("merge3Lines() = %q, want %q", got, test.want)
+			}
+			if conflict != test.wantConflict {
+				t.Errorf("merge3Lines() conflict = %t, want %t", conflict, test.wantConflict)
+			}
+		})
+	}
+}
+
+// TestConflictsBlockCommit checks a conflicted cherry-pick can only be committed once every conflicted path was
+// added again
+func TestConflictsBlockCommit(t *testing.T) {
+	repo := newTestRepo(t)
+	base := repo.commitFiles(t, "base", map[string]string{"f": "0\n"})
+	picked := repo.commitFiles(t, "one", map[string]string{"f": "1\n"})
+	runCommand(t, common.CHECKOUT, base)
+	repo.commitFiles(t, "two", map[string]string{"f": "2\n"})
+
+	runCommand(t, common.CHERRY_PICK, picked)
+	if got := readConflicts(); len(got) != 1 || got[0] != "f" {
+		t.Fatalf("conflicts after the pick = %v, want [f]", got)
+	}
+	if output := runCommand(t, common.COMMIT, common.MESSAGE_FLAG, "resolved"); !strings.Contains(output, "unmerged files") {
+		t.Fatalf("commit with conflicts printed %q", output)
+	}
+
+	repo.writeFiles(t, map[string]string{"f": "resolved\n"})
+	runCommand(t, common.ADD, "f")
+	runCommand(t, common.COMMIT, common.MESSAGE_FLAG, "resolved")
+	if got := commitMessages(t)[0]; got != "resolved" {
+		t.Errorf("HEAD is %q, want the resolved commit", got)
+	}
+	if readStateFile(common.CHERRY_PICK_HEAD_FILE_NAME) != "" || len(readConflicts()) != 0 {
+		t.Error("the cherry-pick state outlived its commit")
+	}
+}
@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"version_control_go/common"
+)
+
+// Object types recorded in the header every stored object starts with
+const (
+	blobType   = "blob"
+	treeType   = "tree"
+	commitType = "commit"
+)
+
+var errObjectNotFound = errors.New("object not found")
+var errCorruptObject = errors.New("corrupt object")
+
+// treeEntry is one tracked file: the blob holding its content and its slash separated path
+type treeEntry struct {
+	hash string
+	path string
+}
+
+type signature struct {
+	name string
+	when time.Time
+}
+
+type commitObject struct {
+	tree      string
+	parents   []string
+	author    signature
+	committer signature
+	message   string
+}
+
+func objectPath(hash string) string {
+	return vcsPath(common.OBJECTS_DIR_NAME, hash[:2], hash[2:])
+}
+
+func hasObject(hash string) bool {
+	if len(hash) < 3 {
+		return false
+	}
+	_, err := os.Stat(objectPath(hash))
+	return err == nil || hasPackedObject(hash)
+}
+
+// writeObject stores data compressed behind a "<type> <size>\0" header
+func writeObject(hash string, kind string, data []byte) {
+	// Objects are content addressed, so an existing one never needs rewriting
+	path := objectPath(hash)
+	if fileExists(path) {
+		// Touching it keeps prune from removing an object that was just written again
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return
+	}
+	if hasPackedObject(hash) {
+		return
+	}
+	writeLooseObject(hash, kind, data)
+}
+
+// writeLooseObject stores one object in its own file whether or not a pack already holds it
+func writeLooseObject(hash string, kind string, data []byte) {
+	header := fmt.Sprintf("%s %d\x00", kind, len(data))
+	stored := deflate(append([]byte(header), data...))
+
+	path := objectPath(hash)
+	createDir(filepath.Dir(path))
+	if err := os.WriteFile(path, stored, 0444); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readObject(hash string) ([]byte, error) {
+	_, data, err := readObjectWithType(hash)
+	return data, err
+}
+
+// readObjectWithType returns the type and content of an object
+func readObjectWithType(hash string) (string, []byte, error) {
+	if len(hash) < 3 {
+		return "", nil, errObjectNotFound
+	}
+
+	// Loose objects are checked first, gc moves them into packs
+	stored, err := os.ReadFile(objectPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return readPackedObject(hash)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return decodeLooseObject(stored)
+}
+
+func decodeLooseObject(stored []byte) (string, []byte, error) {
+	inflated, err := inflate(bytes.NewReader(stored))
+	if err != nil {
+		return "", nil, errCorruptObject
+	}
+
+	header, data, found := bytes.Cut(inflated, []byte{0})
+	if !found {
+		return "", nil, errCorruptObject
+	}
+	kind, sizeStr, _ := strings.Cut(string(header), " ")
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size != len(data) || !isObjectType(kind) {
+		return "", nil, errCorruptObject
+	}
+	return kind, data, nil
+}
+
+func isObjectType(kind string) bool {
+	return kind == blobType || kind == treeType || kind == commitType
+}
+
+// expandObjectPrefix returns the single object whose hash starts with prefix, or ""
+func expandObjectPrefix(prefix string) string {
+	const minPrefixLength = 4
+
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < minPrefixLength || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return ""
+	}
+
+	matches := map[string]bool{}
+	entries, _ := os.ReadDir(vcsPath(common.OBJECTS_DIR_NAME, prefix[:2]))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix[2:]) {
+			matches[prefix[:2]+entry.Name()] = true
+		}
+	}
+	for _, indexPath := range packIndexPaths() {
+		index, err := openPackIndex(indexPath)
+		if err != nil {
+			continue
+		}
+		hashes, _ := index.hashesWithPrefix(prefix)
+		index.close()
+		for _, hash := range hashes {
+			matches[hash] = true
+		}
+	}
+
+	if len(matches) != 1 {
+		return ""
+	}
+	for hash := range matches {
+		return hash
+	}
+	return ""
+}
+
+// copyObjects copies every object srcDir has and dstDir lacks
+func copyObjects(srcDir string, dstDir string) {
+	srcObjectsDir := filepath.Join(srcDir, common.OBJECTS_DIR_NAME)
+	dstObjectsDir := filepath.Join(dstDir, common.OBJECTS_DIR_NAME)
+
+	err := filepath.WalkDir(srcObjectsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(srcObjectsDir, path)
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(dstObjectsDir, relPath)
+		if fileExists(dstPath) {
+			return nil
+		}
+		createDir(filepath.Dir(dstPath))
+		return copySingleFile(path, dstPath)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func copySingleFile(src, dst string) error {
+	// Open the source file for reading
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	// Create the destination file for writing
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	// Copy the contents from source to destination
+	_, err = io.Copy(destFile, sourceFile)
+	if err != nil {
+		return err
+	}
+
+	// Ensure that any writes to destFile are committed to stable storage
+	return destFile.Sync()
+}
+
+// hashObject names content of the given type with the hash algorithm of the repository
+func hashObject(kind string, data []byte) string {
+	return repoHasher().Sum(kind, data)
+}
+
+func hashBlob(data []byte) string {
+	return hashObject(blobType, data)
+}
+
+func writeBlob(data []byte) string {
+	hash := hashBlob(data)
+	writeObject(hash, blobType, data)
+	return hash
+}
+
+func writeBlobFromFile(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return writeBlob(data), nil
+}
+
+func encodeTree(entries []treeEntry) string {
+	sorted := append([]treeEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+
+	var builder strings.Builder
+	for _, entry := range sorted {
+		builder.WriteString(entry.hash + " " + entry.path + "\n")
+	}
+	return builder.String()
+}
+
+func writeTree(entries []treeEntry) string {
+	content := encodeTree(entries)
+	hash := hashObject(treeType, []byte(content))
+	writeObject(hash, treeType, []byte(content))
+	return hash
+}
+
+func parseTree(data []byte) ([]treeEntry, error) {
+	var entries []treeEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		hash, path, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("malformed tree entry %q", line)
+		}
+		entries = append(entries, treeEntry{hash: hash, path: path})
+	}
+	return entries, nil
+}
+
+func readTree(hash string) ([]treeEntry, error) {
+	data, err := readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return parseTree(data)
+}
+
+func newSignature(name string) signature {
+	return signature{name: name, when: time.Now()}
+}
+
+func (s signature) String() string {
+	return fmt.Sprintf("%s %d %s", s.name, s.when.Unix(), s.when.Format("-0700"))
+}
+
+func parseSignature(value string) (signature, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return signature{}, fmt.Errorf("malformed signature %q", value)
+	}
+
+	// The name may contain spaces, the timestamp and zone are always the last two fields
+	seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+	if err != nil {
+		return signature{}, fmt.Errorf("malformed signature %q", value)
+	}
+	zone, err := time.Parse("-0700", fields[len(fields)-1])
+	if err != nil {
+		return signature{}, fmt.Errorf("malformed signature %q", value)
+	}
+
+	when := time.Unix(seconds, 0).In(zone.Location())
+	name := strings.Join(fields[:len(fields)-2], " ")
+	return signature{name: name, when: when}, nil
+}
+
+func encodeCommit(commit commitObject) string {
+	var builder strings.Builder
+	builder.WriteString("tree " + commit.tree + "\n")
+	for _, parent := range commit.parents {
+		builder.WriteString("parent " + parent + "\n")
+	}
+	builder.WriteString("author " + commit.author.String() + "\n")
+	builder.WriteString("committer " + commit.committer.String() + "\n")
+	builder.WriteString("\n" + commit.message)
+	return builder.String()
+}
+
+func writeCommit(commit commitObject) string {
+	content := encodeCommit(commit)
+	hash := hashObject(commitType, []byte(content))
+	writeObject(hash, commitType, []byte(content))
+	return hash
+}
+
+func parseCommit(data []byte) (commitObject, error) {
+	var commit commitObject
+
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	commit.message = message
+
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		var err error
+		switch key {
+		case "tree":
+			commit.tree = value
+		case "parent":
+			commit.parents = append(commit.parents, value)
+		case "author":
+			commit.author, err = parseSignature(value)
+		case "committer":
+			commit.committer, err = parseSignature(value)
+		}
+		if err != nil {
+			return commit, err
+		}
+	}
+
+	if commit.tree == "" {
+		return commit, errors.New("commit has no tree")
+	}
+	return commit, nil
+}
+
+func readCommit(hash string) (commitObject, error) {
+	data, err := readObject(hash)
+	if err != nil {
+		return commitObject{}, err
+	}
+	return parseCommit(data)
+}
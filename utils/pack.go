@@ -0,0 +1,471 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"version_control_go/common"
+)
+
+// A pack holds many objects in one file; its index maps each hash to where the object starts.
+//
+// pack:  "SVCSPACK" version count, then per object: kind, type, [base hash], compressed length, zlib data
+// index: "SVCSIDX1" count, then fixed size records of a padded hash and an offset, sorted by hash
+const packMagic = "SVCSPACK"
+const packVersion = 2
+const packHeaderSize = len(packMagic) + 8
+const packIndexMagic = "SVCSIDX1"
+const packHashWidth = 64
+const packIndexRecordSize = packHashWidth + 8
+const packIndexHeaderSize = len(packIndexMagic) + 4
+
+const packFullEntry = 0
+const packDeltaEntry = 1
+
+const deltaCopy = 1
+const deltaInsert = 2
+
+var errCorruptPack = errors.New("corrupt pack")
+
+// packObjectTypes gives each object type its code in a pack entry; 0 is never written, an entry with it is corrupt
+var packObjectTypes = []string{"", blobType, treeType, commitType}
+
+func packDir() string {
+	return vcsPath(common.OBJECTS_DIR_NAME, common.PACK_DIR_NAME)
+}
+
+// packIndexPaths returns the index of every pack in the repository
+func packIndexPaths() []string {
+	paths, err := filepath.Glob(filepath.Join(packDir(), "*"+common.PACK_INDEX_EXT))
+	if err != nil {
+		return nil
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func packPathOfIndex(indexPath string) string {
+	return strings.TrimSuffix(indexPath, common.PACK_INDEX_EXT) + common.PACK_EXT
+}
+
+// packIndex reads records straight from the index file so a lookup costs O(log n) reads
+type packIndex struct {
+	file  *os.File
+	count int
+}
+
+func openPackIndex(path string) (*packIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, packIndexHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil || string(header[:len(packIndexMagic)]) != packIndexMagic {
+		file.Close()
+		return nil, errCorruptPack
+	}
+	return &packIndex{file: file, count: int(binary.BigEndian.Uint32(header[len(packIndexMagic):]))}, nil
+}
+
+func (index *packIndex) close() {
+	index.file.Close()
+}
+
+func (index *packIndex) record(i int) (string, int64, error) {
+	record := make([]byte, packIndexRecordSize)
+	if _, err := index.file.ReadAt(record, int64(packIndexHeaderSize+i*packIndexRecordSize)); err != nil {
+		return "", 0, err
+	}
+	hash := strings.TrimRight(string(record[:packHashWidth]), " ")
+	return hash, int64(binary.BigEndian.Uint64(record[packHashWidth:])), nil
+}
+
+// search returns the position of the first record not less than hash
+func (index *packIndex) search(hash string) (int, error) {
+	var searchErr error
+	position := sort.Search(index.count, func(i int) bool {
+		recordHash, _, err := index.record(i)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return padHash(recordHash) >= padHash(hash)
+	})
+	return position, searchErr
+}
+
+func (index *packIndex) lookup(hash string) (int64, bool, error) {
+	position, err := index.search(hash)
+	if err != nil || position >= index.count {
+		return 0, false, err
+	}
+	recordHash, offset, err := index.record(position)
+	if err != nil {
+		return 0, false, err
+	}
+	return offset, recordHash == hash, nil
+}
+
+// hashesWithPrefix returns every hash in the index that starts with prefix
+func (index *packIndex) hashesWithPrefix(prefix string) ([]string, error) {
+	position, err := index.search(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for ; position < index.count; position++ {
+		hash, _, err := index.record(position)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// padHash makes hashes of different lengths sort the same way as the padded index records
+func padHash(hash string) string {
+	if len(hash) >= packHashWidth {
+		return hash
+	}
+	return hash + strings.Repeat(" ", packHashWidth-len(hash))
+}
+
+// findPackedObject returns the pack and offset of hash, or "" if no pack has it
+func findPackedObject(hash string) (string, int64, error) {
+	for _, indexPath := range packIndexPaths() {
+		index, err := openPackIndex(indexPath)
+		if err != nil {
+			return "", 0, err
+		}
+		offset, found, err := index.lookup(hash)
+		index.close()
+		if err != nil {
+			return "", 0, err
+		}
+		if found {
+			return packPathOfIndex(indexPath), offset, nil
+		}
+	}
+	return "", 0, nil
+}
+
+func hasPackedObject(hash string) bool {
+	packPath, _, err := findPackedObject(hash)
+	return err == nil && packPath != ""
+}
+
+func readPackedObject(hash string) (string, []byte, error) {
+	packPath, offset, err := findPackedObject(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	if packPath == "" {
+		return "", nil, errObjectNotFound
+	}
+	return readPackEntry(packPath, offset, hash)
+}
+
+// readPackEntry inflates the entry at offset and, for a delta, applies it to its base
+func readPackEntry(packPath string, offset int64, hash string) (string, []byte, error) {
+	file, err := os.Open(packPath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, packHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil || string(header[:len(packMagic)]) != packMagic ||
+		binary.BigEndian.Uint32(header[len(packMagic):]) != packVersion {
+		return "", nil, errCorruptPack
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	reader := bufio.NewReader(file)
+
+	entryKind, err := reader.ReadByte()
+	if err != nil {
+		return "", nil, errCorruptPack
+	}
+	typeCode, err := reader.ReadByte()
+	if err != nil || typeCode == 0 || int(typeCode) >= len(packObjectTypes) {
+		return "", nil, errCorruptPack
+	}
+	objectType := packObjectTypes[typeCode]
+	baseHash := ""
+	if entryKind == packDeltaEntry {
+		if baseHash, err = readPackString(reader); err != nil {
+			return "", nil, errCorruptPack
+		}
+	}
+	compressedLength, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", nil, errCorruptPack
+	}
+
+	payload, err := inflate(io.LimitReader(reader, int64(compressedLength)))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", hash, err)
+	}
+	if entryKind == packFullEntry {
+		return objectType, payload, nil
+	}
+
+	base, err := readObject(baseHash)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := applyDelta(base, payload)
+	return objectType, data, err
+}
+
+func packObjectTypeCode(kind string) byte {
+	for code, name := range packObjectTypes {
+		if name == kind {
+			return byte(code)
+		}
+	}
+	return 0
+}
+
+func readPackString(reader *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil || length > packHashWidth {
+		return "", errCorruptPack
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(reader, value); err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// deflate compresses with the level configured in core.compression
+func deflate(data []byte) []byte {
+	var compressed bytes.Buffer
+	writer, _ := zlib.NewWriterLevel(&compressed, compressionLevel())
+	writer.Write(data)
+	writer.Close()
+	return compressed.Bytes()
+}
+
+func inflate(r io.Reader) ([]byte, error) {
+	reader, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// encodeDelta describes target as copies of base line runs and inserted bytes
+func encodeDelta(base []byte, target []byte) []byte {
+	baseLines, targetLines := splitLines(string(base)), splitLines(string(target))
+	baseOffsets := make([]int, len(baseLines)+1)
+	for i, line := range baseLines {
+		baseOffsets[i+1] = baseOffsets[i] + len(line)
+	}
+
+	var delta bytes.Buffer
+	putUvarint(&delta, uint64(len(base)))
+	putUvarint(&delta, uint64(len(target)))
+
+	var pending []byte
+	flushInsert := func() {
+		if len(pending) > 0 {
+			delta.WriteByte(deltaInsert)
+			putUvarint(&delta, uint64(len(pending)))
+			delta.Write(pending)
+			pending = nil
+		}
+	}
+
+	targetIndex := 0
+	matches := matchLines(baseLines, targetLines)
+	for i := 0; i < len(matches); {
+		for targetIndex < matches[i][1] {
+			pending = append(pending, targetLines[targetIndex]...)
+			targetIndex++
+		}
+
+		// Consecutive matched lines become a single copy
+		j := i + 1
+		for j < len(matches) && matches[j][0] == matches[j-1][0]+1 && matches[j][1] == matches[j-1][1]+1 {
+			j++
+		}
+		flushInsert()
+		start, end := baseOffsets[matches[i][0]], baseOffsets[matches[j-1][0]+1]
+		delta.WriteByte(deltaCopy)
+		putUvarint(&delta, uint64(start))
+		putUvarint(&delta, uint64(end-start))
+
+		targetIndex = matches[j-1][1] + 1
+		i = j
+	}
+	for ; targetIndex < len(targetLines); targetIndex++ {
+		pending = append(pending, targetLines[targetIndex]...)
+	}
+	flushInsert()
+
+	return delta.Bytes()
+}
+
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	reader := bytes.NewReader(delta)
+	baseLength, err := binary.ReadUvarint(reader)
+	if err != nil || baseLength != uint64(len(base)) {
+		return nil, errCorruptPack
+	}
+	targetLength, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, errCorruptPack
+	}
+
+	// The lengths come from the delta, so nothing is allocated or copied beyond what its bytes can account for
+	capacity := uint64(len(base) + len(delta))
+	if targetLength < capacity {
+		capacity = targetLength
+	}
+	target := make([]byte, 0, capacity)
+	for reader.Len() > 0 {
+		op, _ := reader.ReadByte()
+		switch op {
+		case deltaCopy:
+			start, err1 := binary.ReadUvarint(reader)
+			length, err2 := binary.ReadUvarint(reader)
+			if err1 != nil || err2 != nil || start > uint64(len(base)) || length > uint64(len(base))-start ||
+				length > targetLength-uint64(len(target)) {
+				return nil, errCorruptPack
+			}
+			target = append(target, base[start:start+length]...)
+		case deltaInsert:
+			length, err := binary.ReadUvarint(reader)
+			if err != nil || length > uint64(reader.Len()) || length > targetLength-uint64(len(target)) {
+				return nil, errCorruptPack
+			}
+			chunk := make([]byte, length)
+			reader.Read(chunk)
+			target = append(target, chunk...)
+		default:
+			return nil, errCorruptPack
+		}
+	}
+
+	if uint64(len(target)) != targetLength {
+		return nil, errCorruptPack
+	}
+	return target, nil
+}
+
+// deltaTargetLength returns the length of the object a delta rebuilds, as the delta claims it
+func deltaTargetLength(delta []byte) (uint64, error) {
+	reader := bytes.NewReader(delta)
+	if _, err := binary.ReadUvarint(reader); err != nil {
+		return 0, errCorruptPack
+	}
+	targetLength, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return 0, errCorruptPack
+	}
+	return targetLength, nil
+}
+
+func putUvarint(buffer *bytes.Buffer, value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	buffer.Write(encoded[:binary.PutUvarint(encoded[:], value)])
+}
+
+// writePack stores the objects in a new pack, delta encoding those with an entry in bases, and returns its path
+func writePack(hashes []string, bases map[string]string) (string, int, error) {
+	sorted := append([]string(nil), hashes...)
+	sort.Strings(sorted)
+
+	var pack bytes.Buffer
+	pack.WriteString(packMagic)
+	binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	binary.Write(&pack, binary.BigEndian, uint32(len(sorted)))
+
+	offsets := map[string]int64{}
+	deltas := 0
+	for _, hash := range sorted {
+		objectType, data, err := readObjectWithType(hash)
+		if err != nil {
+			return "", 0, err
+		}
+		offsets[hash] = int64(pack.Len())
+
+		// A delta is only kept when it is smaller than the object itself
+		if baseHash, ok := bases[hash]; ok {
+			if base, err := readObject(baseHash); err == nil {
+				if delta := deflate(encodeDelta(base, data)); len(delta) < len(deflate(data)) {
+					pack.WriteByte(packDeltaEntry)
+					pack.WriteByte(packObjectTypeCode(objectType))
+					putUvarint(&pack, uint64(len(baseHash)))
+					pack.WriteString(baseHash)
+					putUvarint(&pack, uint64(len(delta)))
+					pack.Write(delta)
+					deltas++
+					continue
+				}
+			}
+		}
+
+		compressed := deflate(data)
+		pack.WriteByte(packFullEntry)
+		pack.WriteByte(packObjectTypeCode(objectType))
+		putUvarint(&pack, uint64(len(compressed)))
+		pack.Write(compressed)
+	}
+
+	name := fmt.Sprintf("pack-%x", sha256.Sum256(pack.Bytes()))
+	createDir(packDir())
+	packPath := filepath.Join(packDir(), name+common.PACK_EXT)
+	if err := writeFileSynced(packPath, pack.Bytes()); err != nil {
+		return "", 0, err
+	}
+
+	// The index is written last so a pack is only used once it is complete
+	var index bytes.Buffer
+	index.WriteString(packIndexMagic)
+	binary.Write(&index, binary.BigEndian, uint32(len(sorted)))
+	for _, hash := range sorted {
+		index.WriteString(padHash(hash))
+		binary.Write(&index, binary.BigEndian, uint64(offsets[hash]))
+	}
+	if err := writeFileSynced(filepath.Join(packDir(), name+common.PACK_INDEX_EXT), index.Bytes()); err != nil {
+		return "", 0, err
+	}
+	return packPath, deltas, nil
+}
+
+func writeFileSynced(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
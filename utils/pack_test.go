@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		base, target string
+	}{
+		{"identical", "a\nb\nc\n", "a\nb\nc\n"},
+		{"appended line", "a\nb\n", "a\nb\nc\n"},
+		{"prepended line", "a\nb\n", "x\na\nb\n"},
+		{"replaced middle", numberedLines(1, 50), numberedLines(1, 20) + "new\n" + numberedLines(22, 50)},
+		{"from empty", "", "a\nb\n"},
+		{"to empty", "a\nb\n", ""},
+		{"no trailing newline", "a\nb", "a\nb\nc"},
+		{"binary", "\x00\x01\x02", "\x00\x01\x02\x03"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta := encodeDelta([]byte(test.base), []byte(test.target))
+			got, err := applyDelta([]byte(test.base), delta)
+			if err != nil {
+				t.Fatalf("applyDelta() = %v", err)
+			}
+			if string(got) != test.target {
+				t.Errorf("applyDelta() = %q, want %q", got, test.target)
+			}
+		})
+	}
+}
+
+func TestApplyDeltaRejectsCorruptDeltas(t *testing.T) {
+	base := []byte("a\nb\n")
+	tests := []struct {
+		name  string
+		delta []byte
+	}{
+		{"other base", encodeDelta([]byte("a\n"), []byte("a\nb\n"))},
+		{"copy past the base", []byte{4, 4, deltaCopy, 2, 4}},
+		{"insert past the end", []byte{4, 4, deltaInsert, 8, 'x'}},
+		{"unknown operation", []byte{4, 4, 9}},
+		{"short target", []byte{4, 8, deltaCopy, 0, 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := applyDelta(base, test.delta); !errors.Is(err, errCorruptPack) {
+				t.Errorf("applyDelta() = %v, want %v", err, errCorruptPack)
+			}
+		})
+	}
+}
+
+// TestGCPackRoundTrip packs a history with a growing file and reads every object back from the pack
+func TestGCPackRoundTrip(t *testing.T) {
+	repo := newTestRepo(t)
+	for i := 1; i <= 4; i++ {
+		repo.commitFiles(t, fmt.Sprint("commit ", i), map[string]string{
+			"big":                  numberedLines(1, 100*i),
+			fmt.Sprint("small", i): fmt.Sprintln(i),
+		})
+	}
+	objects := map[string][]byte{}
+	for hash := range reachableObjectSet() {
+		data, err := readObject(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[hash] = data
+	}
+
+	output := runCommand(t, common.GC)
+	var count, deltas int
+	counts := packedObjects[:strings.Index(packedObjects, " into")]
+	if _, err := fmt.Sscanf(output, counts, &count, &deltas); err != nil {
+		t.Fatalf("gc printed %q: %v", output, err)
+	}
+	if count != len(objects) || deltas == 0 {
+		t.Errorf("gc packed %d objects with %d deltas, want %d objects and some deltas", count, deltas, len(objects))
+	}
+	if loose, _ := looseObjects(); len(loose) != 0 {
+		t.Errorf("%d objects are still loose after gc", len(loose))
+	}
+	if indexes := packIndexPaths(); len(indexes) != 1 {
+		t.Fatalf("gc left %d packs, want 1", len(indexes))
+	}
+
+	for hash, want := range objects {
+		_, got, err := readPackedObject(hash)
+		if err != nil {
+			t.Fatalf("readPackedObject(%s) = %v", hash, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("object %s changed in the pack", hash)
+		}
+	}
+	if output := runCommand(t, common.GC); !strings.Contains(output, nothingToPack) {
+		t.Errorf("a second gc printed %q", output)
+	}
+}
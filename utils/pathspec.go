@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const unknownPathspecMagic = "Unknown pathspec magic '%s' in '%s'."
+
+// globMeta are the characters that make a pathspec a pattern rather than a path
+const globMeta = "*?["
+
+// Pathspec magic, written ":(exclude,icase)pattern"; ":!" and ":^" are short for exclude and ":/" for top
+const (
+	magicExclude = "exclude"
+	magicIcase   = "icase"
+	magicLiteral = "literal"
+	magicGlob    = "glob"
+	magicTop     = "top"
+)
+
+// pathspecItem is one argument naming paths: a file or a directory with everything below it, or a glob where
+// "**" stands for any number of directories
+type pathspecItem struct {
+	original string
+	pattern  string
+	exclude  bool
+	icase    bool
+	literal  bool
+}
+
+// pathspec is what the path arguments of a command select, the paths some item names minus the ones an exclude
+// item names
+type pathspec []pathspecItem
+
+// parsePathspec reads arguments given relative to the current directory, or to the top of the work tree with
+// the top magic
+func parsePathspec(args []string) (pathspec, error) {
+	var spec pathspec
+	for _, arg := range args {
+		item := pathspecItem{original: arg}
+		pattern, top := arg, false
+
+		switch {
+		case strings.HasPrefix(pattern, ":("):
+			end := strings.Index(pattern, ")")
+			if end < 0 {
+				return nil, fmt.Errorf(unknownPathspecMagic, pattern[2:], arg)
+			}
+			for _, magic := range strings.Split(pattern[2:end], ",") {
+				switch strings.TrimSpace(magic) {
+				case magicExclude:
+					item.exclude = true
+				case magicIcase:
+					item.icase = true
+				case magicLiteral:
+					item.literal = true
+				case magicGlob:
+				case magicTop:
+					top = true
+				default:
+					return nil, fmt.Errorf(unknownPathspecMagic, magic, arg)
+				}
+			}
+			pattern = pattern[end+1:]
+		case strings.HasPrefix(pattern, ":!"), strings.HasPrefix(pattern, ":^"):
+			item.exclude, pattern = true, pattern[2:]
+		case strings.HasPrefix(pattern, ":/"):
+			top, pattern = true, pattern[2:]
+		}
+
+		if top {
+			item.pattern = path.Clean("/" + pattern)[1:]
+			if item.pattern == "" {
+				item.pattern = "."
+			}
+		} else {
+			relPath, err := workTreePath(pattern)
+			if err != nil {
+				return nil, err
+			}
+			item.pattern = relPath
+		}
+		if item.icase {
+			item.pattern = strings.ToLower(item.pattern)
+		}
+		spec = append(spec, item)
+	}
+	return spec, nil
+}
+
+// matches tells whether the spec selects a work tree path; a spec of only exclude items starts from every path
+func (spec pathspec) matches(name string) bool {
+	included, hasIncludes := false, false
+	for _, item := range spec {
+		if item.exclude {
+			if item.matches(name) {
+				return false
+			}
+			continue
+		}
+		hasIncludes = true
+		included = included || item.matches(name)
+	}
+	return included || !hasIncludes
+}
+
+// filter keeps the paths the spec selects
+func (spec pathspec) filter(names []string) []string {
+	var kept []string
+	for _, name := range names {
+		if spec.matches(name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// unmatched returns the argument of the first item other than an exclude that selects none of names
+func (spec pathspec) unmatched(names []string) (string, bool) {
+	for _, item := range spec {
+		if item.exclude {
+			continue
+		}
+		found := false
+		for _, name := range names {
+			if item.matches(name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return item.original, true
+		}
+	}
+	return "", false
+}
+
+// matches tells whether the item names the path, a directory above it or, as a glob, the path or a directory
+// above it
+func (item pathspecItem) matches(name string) bool {
+	if item.icase {
+		name = strings.ToLower(name)
+	}
+	if item.pattern == "." || name == item.pattern || strings.HasPrefix(name, item.pattern+"/") {
+		return true
+	}
+	if item.literal || !strings.ContainsAny(item.pattern, globMeta) {
+		return false
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if matchGlob(item.pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash separated path against a pattern where "**" stands for any number of directories
+// and the other segments follow path.Match
+func matchGlob(pattern string, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchGlobSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], name[0]); err != nil || !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathspec(t *testing.T) {
+	names := []string{"README.md", "[x].go", "docs/Guide.MD", "main.go", "src/a.go", "src/b.txt", "src/lib/c.go", "x.go"}
+	tests := []struct {
+		name string
+		// dir is where the arguments are given, relative to the top of the work tree
+		dir     string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "file", args: []string{"main.go"}, want: "main.go"},
+		{name: "directory", args: []string{"src"}, want: "src/a.go src/b.txt src/lib/c.go"},
+		{name: "everything", args: []string{"."}, want: strings.Join(names, " ")},
+		{name: "glob within a directory", args: []string{"src/*.go"}, want: "src/a.go"},
+		{name: "glob stays in its directory", args: []string{"*.go"}, want: "[x].go main.go x.go"},
+		{name: "double star", args: []string{"**/*.go"}, want: "[x].go main.go src/a.go src/lib/c.go x.go"},
+		{name: "glob naming a directory", args: []string{"s?c/l*"}, want: "src/lib/c.go"},
+		{name: "exclude", args: []string{"src", ":(exclude)src/lib"}, want: "src/a.go src/b.txt"},
+		{name: "only excludes", args: []string{":!src", ":^docs"}, want: "README.md [x].go main.go x.go"},
+		{name: "icase", args: []string{":(icase)DOCS/guide.md"}, want: "docs/Guide.MD"},
+		{name: "case matters by default", args: []string{"docs/guide.md"}, want: ""},
+		{name: "brackets are a glob", args: []string{"[x].go"}, want: "[x].go x.go"},
+		{name: "literal", args: []string{":(literal)[x].go"}, want: "[x].go"},
+		{name: "several items", args: []string{"README.md", "src/lib"}, want: "README.md src/lib/c.go"},
+		{name: "relative to a subdirectory", dir: "src", args: []string{"a.go", "../main.go"}, want: "main.go src/a.go"},
+		{name: "current subdirectory", dir: "src", args: []string{"."}, want: "src/a.go src/b.txt src/lib/c.go"},
+		{name: "top", dir: "src", args: []string{":/main.go", ":(top)docs"}, want: "docs/Guide.MD main.go"},
+		{name: "unknown magic", args: []string{":(bogus)src"}, wantErr: true},
+		{name: "unterminated magic", args: []string{":(icase"}, wantErr: true},
+		{name: "outside the work tree", args: []string{"../elsewhere"}, wantErr: true},
+	}
+
+	repo := newTestRepo(t)
+	files := map[string]string{}
+	for _, name := range names {
+		files[name] = name + "\n"
+	}
+	repo.writeFiles(t, files)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.Chdir(filepath.Join(repo.workTree, filepath.FromSlash(test.dir))); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := parsePathspec(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parsePathspec(%q) = %v, want an error", test.args, spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePathspec(%q) = %v", test.args, err)
+			}
+			if got := strings.Join(spec.filter(names), " "); got != test.want {
+				t.Errorf("%q selects %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPathspecUnmatched(t *testing.T) {
+	newTestRepo(t)
+	spec, err := parsePathspec([]string{"a", ":!b", "missing/*", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg, found := spec.unmatched([]string{"a", "b", "c"}); !found || arg != "missing/*" {
+		t.Errorf("unmatched() = %q, %t, want missing/*", arg, found)
+	}
+	if arg, found := spec.unmatched([]string{"a", "c", "missing/d"}); found {
+		t.Errorf("unmatched() = %q with every item matching", arg)
+	}
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"version_control_go/common"
+)
+
+const prunedObjects = "Removed %d unreachable objects, reclaimed %d bytes.\n"
+const invalidExpiry = "Can't read the date '%s'.\n"
+
+// defaultPruneExpiry is the grace period an unreachable object gets before it may be deleted
+const defaultPruneExpiry = 14 * 24 * time.Hour
+
+const expiryNow = "now"
+const expiryNever = "never"
+const expiryAgo = "ago"
+
+// expiryUnits are the units a relative date such as "2.weeks.ago" or "3 days ago" may use
+var expiryUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+func pruneCase(consoleArgs []string) {
+	expire, ok := expiryOption(consoleArgs)
+	if !ok {
+		return
+	}
+
+	looseHashes, _ := looseObjects()
+	reachable := reachableObjectSet()
+	removed, reclaimed := pruneLooseObjects(looseHashes, reachable, expire)
+	removeEmptyObjectDirs()
+	fmt.Printf(prunedObjects, removed, reclaimed)
+}
+
+// expiryOption reads "--expire=<date>" or "--expire <date>", defaulting to the grace period before now
+func expiryOption(consoleArgs []string) (time.Time, bool) {
+	now := time.Now()
+	value := ""
+	args := consoleArgs[2:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], common.EXPIRE_FLAG+"="):
+			value = strings.TrimPrefix(args[i], common.EXPIRE_FLAG+"=")
+		case args[i] == common.EXPIRE_FLAG:
+			if i+1 == len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, common.EXPIRE_FLAG)
+				return time.Time{}, false
+			}
+			i++
+			value = args[i]
+		}
+	}
+	if value == "" {
+		return now.Add(-defaultPruneExpiry), true
+	}
+
+	expire, err := parseExpiry(value, now)
+	if err != nil {
+		fmt.Printf(invalidExpiry, value)
+		return time.Time{}, false
+	}
+	return expire, true
+}
+
+// parseExpiry accepts "now", "never", a date, an RFC 3339 time, unix seconds or "<n>.<unit>.ago"
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case expiryNow:
+		return now, nil
+	case expiryNever:
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == expiryAgo {
+		count, err := strconv.Atoi(fields[0])
+		unit, known := expiryUnits[strings.TrimSuffix(fields[1], "s")]
+		if err == nil && count >= 0 && known {
+			return now.Add(-time.Duration(count) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read the date '%s'", value)
+}
+
+// reachableObjectSet marks everything branches, remote tracking branches, HEAD, MERGE_HEAD, the reflogs and the
+// index keep alive
+func reachableObjectSet() map[string]bool {
+	tips := append(localRefTips(), headCommit())
+	tips = append(tips, reflogHashes()...)
+	if mergeHead := readStateFile(common.MERGE_HEAD_FILE_NAME); mergeHead != "" {
+		tips = append(tips, mergeHead)
+	}
+
+	reachable := map[string]bool{}
+	queue := tips
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if hash == "" || reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+
+		// Anything unreadable stops pruning, guessing what it pointed at could delete live objects
+		commit, err := readCommit(hash)
+		if err != nil {
+			log.Fatalf("can't read commit %s: %v", hash, err)
+		}
+		queue = append(queue, commit.parents...)
+		if reachable[commit.tree] {
+			continue
+		}
+		reachable[commit.tree] = true
+
+		entries, err := readTree(commit.tree)
+		if err != nil {
+			log.Fatalf("can't read tree %s: %v", commit.tree, err)
+		}
+		for _, entry := range entries {
+			reachable[entry.hash] = true
+		}
+	}
+
+	for _, entry := range readIndex() {
+		if entry.hash != "" {
+			reachable[entry.hash] = true
+		}
+	}
+	return reachable
+}
+
+// pruneLooseObjects deletes the unreachable loose objects last written before expire and returns how many
+// went and the bytes they took
+func pruneLooseObjects(hashes []string, reachable map[string]bool, expire time.Time) (int, int64) {
+	removed := 0
+	var reclaimed int64
+	for _, hash := range hashes {
+		if reachable[hash] {
+			continue
+		}
+		info, err := os.Stat(objectPath(hash))
+		if err != nil || info.ModTime().After(expire) {
+			continue
+		}
+		removeFile(objectPath(hash))
+		removed++
+		reclaimed += info.Size()
+	}
+	return removed, reclaimed
+}
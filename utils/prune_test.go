@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// TestPruneKeepsReachableObjects builds objects that only the index, MERGE_HEAD or an older stash entry keep
+// alive, next to one nothing refers to, and prunes with no grace period
+func TestPruneKeepsReachableObjects(t *testing.T) {
+	for _, command := range []string{common.PRUNE, common.GC} {
+		t.Run(command, func(t *testing.T) {
+			repo := newTestRepo(t)
+			repo.commitFiles(t, "base", map[string]string{"f": "base\n"})
+
+			// stash@{1} is only in the log of refs/stash once a second entry is pushed
+			repo.writeFiles(t, map[string]string{"f": "older stash\n"})
+			runCommand(t, common.STASH)
+			repo.writeFiles(t, map[string]string{"f": "newer stash\n"})
+			runCommand(t, common.STASH)
+			olderStash := readReflog(common.STASH_REF)[0].newHash
+			if readRef(common.STASH_REF) == olderStash {
+				t.Fatal("the older stash entry is still what refs/stash points at")
+			}
+
+			repo.writeFiles(t, map[string]string{"staged": "only in the index\n"})
+			runCommand(t, common.ADD, "staged")
+
+			mergeBlob := writeBlob([]byte("only in MERGE_HEAD\n"))
+			author := newSignature("tester")
+			mergeHead := writeCommit(commitObject{
+				tree:      writeTree([]treeEntry{{hash: mergeBlob, path: "merged"}}),
+				parents:   []string{headCommit()},
+				author:    author,
+				committer: author,
+				message:   "merged\n",
+			})
+			writeStateFile(common.MERGE_HEAD_FILE_NAME, mergeHead)
+
+			garbage := writeBlob([]byte("nothing refers to this\n"))
+			kept := map[string]string{
+				"older stash":        olderStash,
+				"older stash's blob": hashBlob([]byte("older stash\n")),
+				"index blob":         hashBlob([]byte("only in the index\n")),
+				"MERGE_HEAD":         mergeHead,
+				"MERGE_HEAD's blob":  mergeBlob,
+			}
+
+			sizeBefore := objectStoreSize()
+			output := runCommand(t, command, common.EXPIRE_FLAG+"="+expiryNow)
+			for name, hash := range kept {
+				if !hasObject(hash) {
+					t.Errorf("%s %s was pruned", name, hash)
+				}
+			}
+			if hasObject(garbage) {
+				t.Errorf("the unreachable blob %s was kept", garbage)
+			}
+
+			// Reclaimed is what the object store shrank by, not every byte deleted
+			wantSummary := fmt.Sprintf(prunedObjects, 1, sizeBefore-objectStoreSize())
+			if !strings.HasSuffix(output, wantSummary) {
+				t.Errorf("%s printed %q, want it to end with %q", command, output, wantSummary)
+			}
+		})
+	}
+}
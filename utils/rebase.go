@@ -0,0 +1,499 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"version_control_go/common"
+)
+
+const upstreamWasNotPassed = "Upstream was not passed."
+const rebaseInProgress = "A rebase is in progress, use 'rebase --continue', '--skip' or '--abort'."
+const noRebaseInProgress = "No rebase in progress."
+const upToDate = "Current branch %s is up to date.\n"
+const fastForwardedTo = "Fast-forwarded %s to %s.\n"
+const couldNotApplyRebase = "Could not apply %s... %s\nResolve the conflicts, 'add' the files and run 'rebase --continue', or use 'rebase --skip' or 'rebase --abort'.\n"
+const rebaseSucceeded = "Successfully rebased and updated %s.\n"
+const rebaseAborted = "Rebase aborted, back on %s.\n"
+const nothingToDo = "Nothing to do."
+const invalidTodoLine = "Invalid line in the rebase plan: %s\n"
+const squashWithoutPrevious = "Can't '%s' without a previous commit.\n"
+const stoppedAt = "Stopped at %s... %s\nYou can amend the commit now by staging changes, then run 'rebase --continue'.\n"
+const emptyCommitMessage = "Aborting commit due to empty commit message, run 'rebase --continue' to edit it again."
+const editorFailed = "There was a problem with the editor: %v\n"
+
+// Files of vcs/rebase-merge that let a rebase stop on a conflict and carry on in a later run
+const (
+	rebaseHeadNameFile = "head-name"
+	rebaseOrigHeadFile = "orig-head"
+	rebaseOntoFile     = "onto"
+	rebaseTodoFile     = "todo"
+	rebaseDoneFile     = "done"
+	rebaseCurrentFile  = "current"
+	rebaseActionFile   = "current-action"
+	rebaseAmendFile    = "amend"
+	rebaseMessageFile  = "message"
+)
+
+// Actions of an interactive rebase plan
+const (
+	rebasePick   = "pick"
+	rebaseReword = "reword"
+	rebaseEdit   = "edit"
+	rebaseSquash = "squash"
+	rebaseFixup  = "fixup"
+	rebaseDrop   = "drop"
+)
+
+// rebaseActions maps every action and its one letter short form onto the action
+var rebaseActions = map[string]string{
+	rebasePick: rebasePick, "p": rebasePick,
+	rebaseReword: rebaseReword, "r": rebaseReword,
+	rebaseEdit: rebaseEdit, "e": rebaseEdit,
+	rebaseSquash: rebaseSquash, "s": rebaseSquash,
+	rebaseFixup: rebaseFixup, "f": rebaseFixup,
+	rebaseDrop: rebaseDrop, "d": rebaseDrop,
+}
+
+const rebaseTodoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's message
+# d, drop <commit> = remove commit
+#
+# Lines run from top to bottom and can be reordered. Removing every line aborts the rebase.
+`
+
+var errInvalidTodoLine = errors.New("invalid line in the rebase plan")
+var errEmptyMessage = errors.New("empty commit message")
+
+// rebaseStep is one parsed line of the plan
+type rebaseStep struct {
+	action string
+	hash   string
+}
+
+const detachedHeadName = "detached HEAD"
+
+// rebaseCase replays the commits of the current branch that upstream lacks on top of upstream, one at a time
+func rebaseCase(consoleArgs []string) {
+	requireWorkTree()
+
+	args := consoleArgs[2:]
+	interactive := len(args) > 0 && args[0] == common.INTERACTIVE_FLAG
+	if interactive {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Println(upstreamWasNotPassed)
+		return
+	}
+	switch args[0] {
+	case common.CONTINUE_FLAG:
+		if requireRebaseInProgress() {
+			continueRebase()
+		}
+		return
+	case common.SKIP_FLAG:
+		if requireRebaseInProgress() {
+			skipRebaseStep()
+		}
+		return
+	case common.ABORT_FLAG:
+		if requireRebaseInProgress() {
+			abortRebase()
+		}
+		return
+	}
+
+	if isRebaseInProgress() {
+		fmt.Println(rebaseInProgress)
+		return
+	}
+	upstream, err := resolveRevision(args[0])
+	if err != nil {
+		fmt.Println(commitDoesNotExist)
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+	startRebase(upstream, args[0], rebaseTodo(headCommit(), upstream), interactive)
+}
+
+// rebaseTodo lists "pick <hash> <subject>" for every commit reachable from head but not from upstream,
+// parents before children; merge commits are left out since their changes come with the commits they merged
+func rebaseTodo(head string, upstream string) []string {
+	excluded := ancestorsOf(upstream)
+	visited := map[string]bool{}
+	var todo []string
+
+	var visit func(hash string)
+	visit = func(hash string) {
+		if hash == "" || visited[hash] || excluded[hash] {
+			return
+		}
+		visited[hash] = true
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, parent := range commit.parents {
+			visit(parent)
+		}
+		if len(commit.parents) < 2 {
+			todo = append(todo, rebasePick+" "+hash+" "+commitSubject(commit))
+		}
+	}
+	visit(head)
+	return todo
+}
+
+// startRebase detaches HEAD on upstream and works through todo; a branch already on top of upstream is left
+// alone unless the plan is edited interactively
+func startRebase(upstream string, upstreamName string, todo []string, interactive bool) {
+	head := headCommit()
+	headName := detachedHeadName
+	if ref, _ := readHead(); ref != "" {
+		headName = ref
+	}
+
+	if isAncestor(upstream, head) && !interactive {
+		fmt.Printf(upToDate, headLabel())
+		return
+	}
+	if head == "" || head != upstream && isAncestor(head, upstream) {
+		// Nothing of our own to replay, the branch just moves forward
+		checkoutCommit(upstream)
+		updateHead(upstream, "rebase: fast-forward onto "+upstreamName)
+		fmt.Printf(fastForwardedTo, headLabel(), upstreamName)
+		return
+	}
+
+	if refuseUntrackedOverwrite(treePaths(treeEntriesOfCommit(upstream))) {
+		return
+	}
+
+	createDir(vcsPath(common.REBASE_DIR_NAME))
+	writeRebaseFile(rebaseHeadNameFile, headName)
+	writeRebaseFile(rebaseOrigHeadFile, head)
+	writeRebaseFile(rebaseOntoFile, upstream)
+	writeRebaseFile(rebaseTodoFile, strings.Join(todo, "\n"))
+	writeRebaseFile(rebaseDoneFile, "")
+
+	if interactive && !editRebaseTodo() {
+		removeRebaseState()
+		return
+	}
+
+	checkoutCommit(upstream)
+	moveHead("", upstream, "rebase: checkout "+upstreamName)
+	runRebaseTodo()
+}
+
+// editRebaseTodo lets the user change the plan in $EDITOR and checks what comes back
+func editRebaseTodo() bool {
+	todo := readRebaseLines(rebaseTodoFile)
+	writeRebaseFile(rebaseTodoFile, strings.Join(todo, "\n")+"\n"+rebaseTodoHelp)
+	if err := launchEditor(rebaseFilePath(rebaseTodoFile)); err != nil {
+		fmt.Printf(editorFailed, err)
+		return false
+	}
+
+	todo = readRebaseLines(rebaseTodoFile)
+	if len(todo) == 0 {
+		fmt.Println(nothingToDo)
+		return false
+	}
+	for i, line := range todo {
+		step, err := parseRebaseStep(line)
+		if err != nil {
+			fmt.Printf(invalidTodoLine, line)
+			return false
+		}
+		if i == 0 && (step.action == rebaseSquash || step.action == rebaseFixup) {
+			fmt.Printf(squashWithoutPrevious, step.action)
+			return false
+		}
+	}
+	writeRebaseFile(rebaseTodoFile, strings.Join(todo, "\n"))
+	return true
+}
+
+// parseRebaseStep reads "<action> <commit> [subject]", accepting the one letter actions
+func parseRebaseStep(line string) (rebaseStep, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return rebaseStep{}, errInvalidTodoLine
+	}
+	action, known := rebaseActions[fields[0]]
+	if !known {
+		return rebaseStep{}, errInvalidTodoLine
+	}
+	hash, err := resolveRevision(fields[1])
+	if err != nil {
+		return rebaseStep{}, err
+	}
+	return rebaseStep{action: action, hash: hash}, nil
+}
+
+// runRebaseTodo carries out the todo list until it is empty or a step stops for the user
+func runRebaseTodo() {
+	for {
+		todo := readRebaseLines(rebaseTodoFile)
+		if len(todo) == 0 {
+			finishRebase()
+			return
+		}
+
+		// The step moves to done first so a stop leaves it as the current one
+		line := todo[0]
+		writeRebaseFile(rebaseTodoFile, strings.Join(todo[1:], "\n"))
+		writeRebaseFile(rebaseDoneFile, strings.Join(append(readRebaseLines(rebaseDoneFile), line), "\n"))
+
+		step, err := parseRebaseStep(line)
+		if err != nil {
+			log.Fatalf("invalid line in the rebase plan: %s", line)
+		}
+		if step.action != rebaseDrop && !applyRebaseStep(step) {
+			return
+		}
+	}
+}
+
+// applyRebaseStep applies the changes of one commit on top of HEAD and commits them as the action says;
+// false means the rebase stopped for the user
+func applyRebaseStep(step rebaseStep) bool {
+	commit, err := readCommit(step.hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	parent := ""
+	if len(commit.parents) > 0 {
+		parent = commit.parents[0]
+	}
+
+	result := mergeCommitChanges(parent, step.hash, shortHash(step.hash)+" ("+commitSubject(commit)+")")
+	if refuseUntrackedOverwrite(append(treePaths(result.entries), sortedKeys(result.conflicts)...)) {
+		requeueRebaseStep()
+		return false
+	}
+	applyMergeResult(result)
+	if len(result.conflicts) > 0 {
+		stopRebaseStep(step)
+		fmt.Printf(couldNotApplyRebase, shortHash(step.hash), commitSubject(commit))
+		return false
+	}
+	return commitRebaseStep(step, result.entries)
+}
+
+// commitRebaseStep records entries for a step whose changes are in place: pick keeps the commit as it was,
+// reword asks for a new message, squash and fixup fold it into HEAD and edit stops afterwards
+func commitRebaseStep(step rebaseStep, entries []treeEntry) bool {
+	commit, err := readCommit(step.hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	subject := commitSubject(commit)
+
+	switch step.action {
+	case rebaseReword:
+		message, err := editRebaseMessage(commit.message)
+		if err != nil {
+			stopRebaseStep(step)
+			return false
+		}
+		commitPickedTree(entries, commit.author, message, "rebase (reword): "+subject)
+	case rebaseSquash, rebaseFixup:
+		head, err := readCommit(headCommit())
+		if err != nil {
+			log.Fatal(err)
+		}
+		message := head.message
+		if step.action == rebaseSquash {
+			combined := "# This is a combination of 2 commits.\n" + head.message + "\n\n" + commit.message
+			if message, err = editRebaseMessage(combined); err != nil {
+				stopRebaseStep(step)
+				return false
+			}
+		}
+		rewriteHeadCommit(entries, message, "rebase ("+step.action+"): "+subject)
+	default:
+		commitPickedTree(entries, commit.author, commit.message, "rebase ("+step.action+"): "+subject)
+	}
+
+	if step.action == rebaseEdit {
+		writeRebaseFile(rebaseAmendFile, headCommit())
+		fmt.Printf(stoppedAt, shortHash(step.hash), subject)
+		return false
+	}
+	return true
+}
+
+// requeueRebaseStep puts the step just moved to done back at the top of the todo list, for a step that could
+// not even start; 'rebase --continue' tries it again
+func requeueRebaseStep() {
+	done := readRebaseLines(rebaseDoneFile)
+	line := done[len(done)-1]
+	writeRebaseFile(rebaseDoneFile, strings.Join(done[:len(done)-1], "\n"))
+	writeRebaseFile(rebaseTodoFile, strings.Join(append([]string{line}, readRebaseLines(rebaseTodoFile)...), "\n"))
+}
+
+// stopRebaseStep remembers the step whose changes are applied but not committed yet
+func stopRebaseStep(step rebaseStep) {
+	writeRebaseFile(rebaseCurrentFile, step.hash)
+	writeRebaseFile(rebaseActionFile, step.action)
+}
+
+// editRebaseMessage opens message in $EDITOR and fails when it comes back empty
+func editRebaseMessage(message string) (string, error) {
+	writeRebaseFile(rebaseMessageFile, message+"\n\n# Lines starting with '#' are ignored, an empty message stops the rebase.")
+	if err := launchEditor(rebaseFilePath(rebaseMessageFile)); err != nil {
+		fmt.Printf(editorFailed, err)
+		return "", err
+	}
+
+	edited := stripComments(readRebaseFile(rebaseMessageFile))
+	if edited == "" {
+		fmt.Println(emptyCommitMessage)
+		return "", errEmptyMessage
+	}
+	return edited, nil
+}
+
+// rewriteHeadCommit replaces HEAD with a commit of entries that keeps HEAD's parents and author
+func rewriteHeadCommit(entries []treeEntry, message string, reflogMessage string) string {
+	head, err := readCommit(headCommit())
+	if err != nil {
+		log.Fatal(err)
+	}
+	hash := writeCommit(commitObject{
+		tree:      writeTree(entries),
+		parents:   head.parents,
+		author:    head.author,
+		committer: newSignature(getConfig(common.USER_NAME_KEY)),
+		message:   message,
+	})
+	updateHead(hash, reflogMessage)
+	return hash
+}
+
+// continueRebase commits the resolved conflicts of the stopped step, or folds staged changes into the commit
+// an edit stopped at, and goes on with the rest
+func continueRebase() {
+	if refuseUnmergedPaths("Continuing") {
+		return
+	}
+
+	entries := fillLegacyIndexEntries(readIndex())
+	writeIndex(entries)
+
+	if current := readRebaseFile(rebaseCurrentFile); current != "" {
+		action := readRebaseFile(rebaseActionFile)
+		if action == "" {
+			action = rebasePick
+		}
+		removeFile(rebaseFilePath(rebaseCurrentFile))
+		removeFile(rebaseFilePath(rebaseActionFile))
+		if !commitRebaseStep(rebaseStep{action: action, hash: current}, entries) {
+			return
+		}
+	} else if amend := readRebaseFile(rebaseAmendFile); amend != "" {
+		removeFile(rebaseFilePath(rebaseAmendFile))
+		head, err := readCommit(headCommit())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if headCommit() == amend && writeTree(entries) != head.tree {
+			rewriteHeadCommit(entries, head.message, "rebase (amend): "+commitSubject(head))
+		}
+	}
+	runRebaseTodo()
+}
+
+// skipRebaseStep drops the stopped step, putting the work tree back to HEAD
+func skipRebaseStep() {
+	checkoutCommit(headCommit())
+	removeFile(rebaseFilePath(rebaseCurrentFile))
+	removeFile(rebaseFilePath(rebaseActionFile))
+	removeFile(rebaseFilePath(rebaseAmendFile))
+	runRebaseTodo()
+}
+
+// abortRebase returns HEAD, the branch and the work tree to where they were before the rebase started
+func abortRebase() {
+	origHead := readRebaseFile(rebaseOrigHeadFile)
+	headName := readRebaseFile(rebaseHeadNameFile)
+
+	checkoutCommit(origHead)
+	if headName == detachedHeadName {
+		moveHead("", origHead, "rebase (abort): returning to "+origHead)
+	} else {
+		moveHead(headName, origHead, "rebase (abort): returning to "+headName)
+	}
+	removeRebaseState()
+	fmt.Printf(rebaseAborted, headLabel())
+}
+
+// finishRebase points the rebased branch at the new tip and attaches HEAD to it again
+func finishRebase() {
+	headName := readRebaseFile(rebaseHeadNameFile)
+	onto := readRebaseFile(rebaseOntoFile)
+	newHead := headCommit()
+
+	if headName != detachedHeadName {
+		moveRef(headName, newHead, "rebase (finish): "+headName+" onto "+onto)
+		moveHead(headName, newHead, "rebase (finish): returning to "+headName)
+	}
+	removeRebaseState()
+	fmt.Printf(rebaseSucceeded, headName)
+}
+
+func isRebaseInProgress() bool {
+	return isDir(vcsPath(common.REBASE_DIR_NAME))
+}
+
+func requireRebaseInProgress() bool {
+	if !isRebaseInProgress() {
+		fmt.Println(noRebaseInProgress)
+		return false
+	}
+	return true
+}
+
+func rebaseFilePath(name string) string {
+	return vcsPath(common.REBASE_DIR_NAME, name)
+}
+
+func readRebaseFile(name string) string {
+	return readStateFile(path.Join(common.REBASE_DIR_NAME, name))
+}
+
+func writeRebaseFile(name string, content string) {
+	writeStateFile(path.Join(common.REBASE_DIR_NAME, name), content)
+}
+
+// readRebaseLines returns the lines of a rebase file such as the todo list, leaving out blank and comment lines
+func readRebaseLines(name string) []string {
+	var lines []string
+	for _, line := range strings.Split(readRebaseFile(name), "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, commentPrefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func removeRebaseState() {
+	if err := os.RemoveAll(vcsPath(common.REBASE_DIR_NAME)); err != nil {
+		log.Fatal(err)
+	}
+}
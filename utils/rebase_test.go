@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// Set for TestEditorHelper, the plan it writes over a rebase todo list and the message over anything else
+const (
+	editorPlanEnv    = "SVCS_TEST_EDITOR_PLAN"
+	editorMessageEnv = "SVCS_TEST_EDITOR_MESSAGE"
+)
+
+// TestEditorHelper is not a test: run as $EDITOR it replaces the file it was given like a user would
+func TestEditorHelper(t *testing.T) {
+	plan, planSet := os.LookupEnv(editorPlanEnv)
+	if !planSet {
+		return
+	}
+	path := os.Args[len(os.Args)-1]
+	content := os.Getenv(editorMessageEnv)
+	if filepath.Base(path) == rebaseTodoFile {
+		content = plan
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestInteractiveRebase(t *testing.T) {
+	tests := []struct {
+		name string
+		// plan names the commits one to four by their subjects
+		plan         string
+		message      string
+		wantSubjects []string
+		wantFiles    map[string]string
+	}{
+		{
+			name:         "squash, fixup and drop",
+			plan:         "pick one\nsquash two\nfixup three\ndrop four\n",
+			message:      "one and two\n\n# the combined message\n",
+			wantSubjects: []string{"one and two", "base"},
+			wantFiles:    map[string]string{"a": "1\n", "b": "2\n", "c": "3\n", "d": ""},
+		},
+		{
+			name:         "reorder with short actions",
+			plan:         "p four\np two\nd one\nd three\n",
+			wantSubjects: []string{"two", "four", "base"},
+			wantFiles:    map[string]string{"a": "", "b": "2\n", "c": "", "d": "4\n"},
+		},
+		{
+			name:         "reword",
+			plan:         "pick one\nreword two\n# three and four are left out\n",
+			message:      "second\n",
+			wantSubjects: []string{"second", "one", "base"},
+			wantFiles:    map[string]string{"a": "1\n", "b": "2\n", "c": "", "d": ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			base := repo.commitFiles(t, "base", map[string]string{"base": "base\n"})
+			hashes := map[string]string{}
+			for _, commit := range []struct{ subject, file, content string }{
+				{"one", "a", "1\n"}, {"two", "b", "2\n"}, {"three", "c", "3\n"}, {"four", "d", "4\n"},
+			} {
+				hashes[commit.subject] = repo.commitFiles(t, commit.subject, map[string]string{commit.file: commit.content})
+			}
+
+			plan := test.plan
+			for subject, hash := range hashes {
+				plan = strings.ReplaceAll(plan, " "+subject+"\n", " "+shortHash(hash)+"\n")
+			}
+			t.Setenv(editorPlanEnv, plan)
+			t.Setenv(editorMessageEnv, test.message)
+			t.Setenv(common.EDITOR_ENV, os.Args[0]+" -test.run=^TestEditorHelper$ --")
+
+			output := runCommand(t, common.REBASE, common.INTERACTIVE_FLAG, base)
+			if isRebaseInProgress() {
+				t.Fatalf("the rebase stopped:\n%s", output)
+			}
+			if got := commitMessages(t); !reflect.DeepEqual(got, test.wantSubjects) {
+				t.Errorf("history = %q, want %q", got, test.wantSubjects)
+			}
+			if branch := currentBranch(); branch != common.DEFAULT_BRANCH {
+				t.Errorf("HEAD is on %q, want %s", branch, common.DEFAULT_BRANCH)
+			}
+			for name, want := range test.wantFiles {
+				if got := repo.readWorkTreeFile(t, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
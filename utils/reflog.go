@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"version_control_go/common"
+)
+
+const reflogLine = "%s %s@{%d}: %s\n"
+const noReflog = "No reflog for '%s'.\n"
+
+// reflogEntry is one movement of a ref: where it was, where it went, who moved it and why
+type reflogEntry struct {
+	oldHash string
+	newHash string
+	who     signature
+	message string
+}
+
+// reflogPath is where the log of a ref such as "HEAD" or "refs/heads/master" is kept
+func reflogPath(ref string) string {
+	return vcsPath(common.LOGS_DIR_NAME, filepath.FromSlash(ref))
+}
+
+// appendReflog records a movement of ref as "<old> <new> <name> <time> <zone>\t<message>"
+func appendReflog(ref string, oldHash string, newHash string, message string) {
+	line := reflogEntry{
+		oldHash: oldHash,
+		newHash: newHash,
+		who:     newSignature(getConfig(common.USER_NAME_KEY)),
+		message: strings.SplitN(message, "\n", 2)[0],
+	}.String()
+
+	path := reflogPath(ref)
+	createDir(filepath.Dir(path))
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (entry reflogEntry) String() string {
+	oldHash := entry.oldHash
+	if oldHash == "" {
+		oldHash = noHash
+	}
+	return fmt.Sprintf("%s %s %s\t%s\n", oldHash, entry.newHash, entry.who, entry.message)
+}
+
+// writeReflog replaces the log of ref, removing it when no entries are left
+func writeReflog(ref string, entries []reflogEntry) {
+	if len(entries) == 0 {
+		removeFile(reflogPath(ref))
+		return
+	}
+
+	var builder strings.Builder
+	for _, entry := range entries {
+		builder.WriteString(entry.String())
+	}
+	if err := os.WriteFile(reflogPath(ref), []byte(builder.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readReflog returns the entries of a ref log, oldest first
+func readReflog(ref string) []reflogEntry {
+	file, err := os.Open(reflogPath(ref))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var entries []reflogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		header, message, _ := strings.Cut(scanner.Text(), "\t")
+		fields := strings.SplitN(header, " ", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		who, err := parseSignature(fields[2])
+		if err != nil {
+			continue
+		}
+		if fields[0] == noHash {
+			fields[0] = ""
+		}
+		entries = append(entries, reflogEntry{oldHash: fields[0], newHash: fields[1], who: who, message: message})
+	}
+	return entries
+}
+
+// moveRef points ref at hash and logs the movement
+func moveRef(ref string, hash string, message string) {
+	oldHash := readRef(ref)
+	writeRef(ref, hash)
+	appendReflog(ref, oldHash, hash, message)
+}
+
+// moveHead points HEAD straight at a commit, or at "ref: <branch>" when ref is given, and logs the movement
+func moveHead(ref string, hash string, message string) {
+	oldHash := headCommit()
+	if ref != "" {
+		writeHeadFile(repoDir, symbolicRefPrefix+ref)
+	} else {
+		writeHeadFile(repoDir, hash)
+	}
+	appendReflog(common.HEAD_FILE_NAME, oldHash, hash, message)
+}
+
+// reflogRefs lists every ref that has a log, HEAD included
+func reflogRefs() []string {
+	var refs []string
+	root := vcsPath(common.LOGS_DIR_NAME)
+	filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err == nil {
+			refs = append(refs, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	return refs
+}
+
+// reflogHashes returns every commit any ref log mentions, so history that was moved away from stays reachable
+func reflogHashes() []string {
+	var hashes []string
+	for _, ref := range reflogRefs() {
+		for _, entry := range readReflog(ref) {
+			for _, hash := range []string{entry.oldHash, entry.newHash} {
+				if hash != "" && hasObject(hash) {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+	}
+	return hashes
+}
+
+// reflogRef maps the part before "@{n}" onto the ref whose log it means; "" and "HEAD" are HEAD
+func reflogRef(name string) string {
+	if name == "" || name == common.HEAD_FILE_NAME {
+		return common.HEAD_FILE_NAME
+	}
+	if name == common.STASH {
+		return common.STASH_REF
+	}
+	if strings.HasPrefix(name, common.REFS_DIR_NAME+"/") {
+		return name
+	}
+	if fileExists(reflogPath(common.HEADS_DIR + "/" + name)) {
+		return common.HEADS_DIR + "/" + name
+	}
+	return common.REMOTES_DIR + "/" + name
+}
+
+// resolveReflogRevision resolves "<ref>@{n}" to where ref was n movements ago
+func resolveReflogRevision(rev string) (string, error) {
+	if !strings.HasSuffix(rev, "}") || !strings.Contains(rev, "@{") {
+		return "", errUnknownRevision
+	}
+	at := strings.LastIndex(rev, "@{")
+	n, err := strconv.Atoi(rev[at+2 : len(rev)-1])
+	if err != nil || n < 0 {
+		return "", errUnknownRevision
+	}
+
+	entries := readReflog(reflogRef(rev[:at]))
+	if n >= len(entries) {
+		return "", errUnknownRevision
+	}
+	return entries[len(entries)-1-n].newHash, nil
+}
+
+// reflogCase lists the log of HEAD, or of the given ref, newest first
+func reflogCase(consoleArgs []string) {
+	name := common.HEAD_FILE_NAME
+	if len(consoleArgs) > 2 {
+		name = consoleArgs[2]
+	}
+
+	entries := readReflog(reflogRef(name))
+	if len(entries) == 0 {
+		fmt.Printf(noReflog, name)
+		return
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		fmt.Printf(reflogLine, shortHash(entries[i].newHash), name, len(entries)-1-i, entries[i].message)
+	}
+}
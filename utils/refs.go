@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"version_control_go/common"
+)
+
+const symbolicRefPrefix = "ref: "
+const refIsLocked = "Unable to create '%s': File exists, another process seems to be updating the ref."
+
+var errUnknownRevision = errors.New("unknown revision")
+
+// readHead returns the branch ref HEAD points at, or "" and the commit hash when HEAD is detached
+func readHead() (string, string) {
+	data, err := os.ReadFile(vcsPath(common.HEAD_FILE_NAME))
+	if err != nil {
+		// Repositories created before HEAD existed start on the default branch
+		return common.HEADS_DIR + "/" + common.DEFAULT_BRANCH, ""
+	}
+
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, symbolicRefPrefix) {
+		return strings.TrimPrefix(content, symbolicRefPrefix), ""
+	}
+	return "", content
+}
+
+func writeHeadFile(dir string, content string) {
+	if err := os.WriteFile(filepath.Join(dir, common.HEAD_FILE_NAME), []byte(content+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readRef returns the commit hash stored in a ref such as "refs/heads/master", or "" if it doesn't exist
+func readRef(name string) string {
+	return readRefIn(repoDir, name)
+}
+
+func readRefIn(dir string, name string) string {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) && !isDir(path) {
+			log.Fatal(err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func writeRef(name string, hash string) {
+	writeRefIn(repoDir, name, hash)
+}
+
+// writeRefIn replaces the ref through a rename so readers on a shared drive never see a partial write
+func writeRefIn(dir string, name string, hash string) {
+	lock, err := lockRefIn(dir, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := lock.commit(hash); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// updateRefIn moves the ref to newHash only if it still points at oldHash, checking while it holds the lock so
+// a concurrent update in another process is never lost
+func updateRefIn(dir string, name string, oldHash string, newHash string) error {
+	lock, err := lockRefIn(dir, name)
+	if err != nil {
+		return err
+	}
+	if readRefIn(dir, name) != oldHash {
+		lock.release()
+		return errStaleRef
+	}
+	return lock.commit(newHash)
+}
+
+// refLock is the "<ref>.lock" file that is created exclusively and renamed over the ref once the new value is in it
+type refLock struct {
+	file *os.File
+	path string
+}
+
+func lockRefIn(dir string, name string) (refLock, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	createDir(filepath.Dir(path))
+
+	file, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return refLock{}, fmt.Errorf(refIsLocked, path+".lock")
+	}
+	if err != nil {
+		return refLock{}, err
+	}
+	return refLock{file: file, path: path}, nil
+}
+
+func (lock refLock) commit(hash string) error {
+	if _, err := lock.file.WriteString(hash + "\n"); err != nil {
+		lock.release()
+		return err
+	}
+	if err := lock.file.Close(); err != nil {
+		os.Remove(lock.file.Name())
+		return err
+	}
+	return os.Rename(lock.file.Name(), lock.path)
+}
+
+func (lock refLock) release() {
+	lock.file.Close()
+	os.Remove(lock.file.Name())
+}
+
+// listRefsIn maps the names below prefix, such as the branches under "refs/heads", to their hashes
+func listRefsIn(dir string, prefix string) map[string]string {
+	refs := map[string]string{}
+	root := filepath.Join(dir, filepath.FromSlash(prefix))
+	if !isDir(root) {
+		return refs
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasSuffix(path, ".lock") {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		refs[filepath.ToSlash(name)] = readRefIn(dir, prefix+"/"+filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return refs
+}
+
+// headBranchIn returns the branch name HEAD of the repository in dir points at, "" when detached
+func headBranchIn(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, common.HEAD_FILE_NAME))
+	if err != nil {
+		return ""
+	}
+	content := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(content, symbolicRefPrefix+common.HEADS_DIR+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(content, symbolicRefPrefix+common.HEADS_DIR+"/")
+}
+
+func isLocalBranch(name string) bool {
+	return isValidRefName(name) && readRef(common.HEADS_DIR+"/"+name) != ""
+}
+
+// isValidRefName rejects names that would escape the refs directory
+func isValidRefName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveRevision turns HEAD, a branch, a remote branch or a (possibly abbreviated) hash into a commit hash
+func resolveRevision(rev string) (string, error) {
+	if rev == common.HEAD_FILE_NAME {
+		if hash := headCommit(); hash != "" {
+			return hash, nil
+		}
+		return "", errUnknownRevision
+	}
+
+	// "<ref>@{n}" is where a ref was n movements ago
+	if strings.Contains(rev, "@{") {
+		return resolveReflogRevision(rev)
+	}
+
+	if isValidRefName(rev) {
+		for _, ref := range []string{rev, common.HEADS_DIR + "/" + rev, common.REMOTES_DIR + "/" + rev} {
+			if !strings.HasPrefix(ref, common.REFS_DIR_NAME+"/") {
+				continue
+			}
+			if hash := readRef(ref); hash != "" {
+				return hash, nil
+			}
+		}
+	}
+
+	hash := expandObjectPrefix(rev)
+	if hash == "" {
+		return "", errUnknownRevision
+	}
+	if _, err := readCommit(hash); err != nil {
+		return "", errUnknownRevision
+	}
+	return hash, nil
+}
+
+// headCommit resolves HEAD to a commit hash, "" while the current branch has no commits
+func headCommit() string {
+	ref, hash := readHead()
+	if ref != "" {
+		return readRef(ref)
+	}
+	return hash
+}
+
+// updateHead moves the current branch, or HEAD itself when detached, to hash and logs it in both
+func updateHead(hash string, message string) {
+	ref, _ := readHead()
+	if ref != "" {
+		oldHash := readRef(ref)
+		moveRef(ref, hash, message)
+		appendReflog(common.HEAD_FILE_NAME, oldHash, hash, message)
+		return
+	}
+	moveHead("", hash, message)
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"version_control_go/common"
+)
+
+const remoteUsage = "Usage: remote [-v] | remote add <name> <path>"
+const remoteAlreadyExists = "Remote '%s' already exists.\n"
+const invalidRemoteName = "'%s' is not a valid remote name.\n"
+const noSuchRemote = "No such remote '%s'.\n"
+const notOnBranch = "You are not currently on a branch."
+const noSuchLocalBranch = "Branch '%s' has no commits to push.\n"
+const noSuchRemoteBranch = "There is no branch '%s' on remote '%s'.\n"
+const fetchFrom = "From %s\n"
+const pushTo = "To %s\n"
+const refNew = " * [new branch]      %s -> %s\n"
+const refUpdated = "   %s..%s  %s -> %s\n"
+const refForced = " + %s...%s %s -> %s (forced update)\n"
+const refRejected = " ! [rejected]        %s -> %s (non-fast-forward)\n"
+const pushRejectedHint = "Updates were rejected because the remote contains work that you do not have locally. Pull first, or use --force."
+const refusingCheckedOutBranch = "Refusing to update the checked out branch '%s' of a repository with a work tree.\n"
+const everythingUpToDate = "Everything up-to-date"
+const pullMergeMessage = "Merge branch '%s' of %s"
+
+const remoteKeyPrefix = "remote."
+const remoteKeySuffix = ".url"
+const shortHashLength = 7
+
+func remoteCase(consoleArgs []string) {
+	if len(consoleArgs) < 3 || consoleArgs[2] == "-v" {
+		verbose := len(consoleArgs) > 2
+		config := readConfigFile(vcsPath(common.CONFIG_FILE_NAME))
+		for _, name := range remoteNames(config) {
+			if verbose {
+				fmt.Printf("%s\t%s\n", name, config[fmt.Sprintf(common.REMOTE_KEY, name)])
+			} else {
+				fmt.Println(name)
+			}
+		}
+		return
+	}
+
+	if consoleArgs[2] != "add" || len(consoleArgs) < 5 {
+		fmt.Println(remoteUsage)
+		return
+	}
+
+	name, url := consoleArgs[3], consoleArgs[4]
+	if !isValidRefName(name) || strings.Contains(name, "/") {
+		fmt.Printf(invalidRemoteName, name)
+		return
+	}
+	if getConfig(fmt.Sprintf(common.REMOTE_KEY, name)) != "" {
+		fmt.Printf(remoteAlreadyExists, name)
+		return
+	}
+
+	// Local paths are stored absolute so the remote works from any directory
+	if !strings.Contains(url, "://") {
+		absURL, err := filepath.Abs(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		url = absURL
+	}
+	setConfig(fmt.Sprintf(common.REMOTE_KEY, name), url)
+}
+
+func remoteNames(config map[string]string) []string {
+	var names []string
+	for key := range config {
+		if strings.HasPrefix(key, remoteKeyPrefix) && strings.HasSuffix(key, remoteKeySuffix) {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(key, remoteKeyPrefix), remoteKeySuffix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openRemote returns the configured location of a remote and the transport that reaches it
+func openRemote(remote string) (string, remoteTransport) {
+	url := getConfig(fmt.Sprintf(common.REMOTE_KEY, remote))
+	if url == "" {
+		fmt.Fprintf(os.Stderr, noSuchRemote, remote)
+		os.Exit(1)
+	}
+
+	transport, err := openTransport(url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, common.NOT_A_REPOSITORY, url)
+		os.Exit(1)
+	}
+	return url, transport
+}
+
+// currentBranch returns the branch HEAD is attached to, "" when detached
+func currentBranch() string {
+	ref, _ := readHead()
+	return strings.TrimPrefix(ref, common.HEADS_DIR+"/")
+}
+
+func shortHash(hash string) string {
+	if len(hash) > shortHashLength {
+		return hash[:shortHashLength]
+	}
+	return hash
+}
+
+// printRefUpdate reports how a ref moved from oldHash to newHash
+func printRefUpdate(oldHash string, newHash string, from string, to string) {
+	switch {
+	case oldHash == "":
+		fmt.Printf(refNew, from, to)
+	case isAncestor(oldHash, newHash):
+		fmt.Printf(refUpdated, shortHash(oldHash), shortHash(newHash), from, to)
+	default:
+		fmt.Printf(refForced, shortHash(oldHash), shortHash(newHash), from, to)
+	}
+}
+
+func fetchCase(consoleArgs []string) {
+	remote := common.DEFAULT_REMOTE
+	if len(consoleArgs) > 2 {
+		remote = consoleArgs[2]
+	}
+	fetchRemote(remote)
+}
+
+// fetchRemote copies the objects of a remote and points refs/remotes/<remote>/* at its branches
+func fetchRemote(remote string) {
+	url, transport := openRemote(remote)
+	advertisement, err := transport.advertisedRefs()
+	if err != nil {
+		exitWithError(err)
+	}
+	requireSameHash(advertisement)
+	branches := advertisement.branches
+	fetchBranchObjects(transport, branches)
+
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	printedHeader := false
+	for _, name := range names {
+		ref := remoteRefName(remote, name)
+		oldHash := readRef(ref)
+		if oldHash == branches[name] {
+			continue
+		}
+
+		if !printedHeader {
+			fmt.Printf(fetchFrom, url)
+			printedHeader = true
+		}
+		printRefUpdate(oldHash, branches[name], name, remote+"/"+name)
+
+		reflogMessage := "fetch: storing head"
+		if oldHash != "" && isAncestor(oldHash, branches[name]) {
+			reflogMessage = "fetch: fast-forward"
+		} else if oldHash != "" {
+			reflogMessage = "fetch: forced-update"
+		}
+		moveRef(ref, branches[name], reflogMessage)
+	}
+}
+
+// fetchBranchObjects asks for the branch tips that are missing locally, telling the remote what we already have
+func fetchBranchObjects(transport remoteTransport, branches map[string]string) {
+	var wants []string
+	for _, hash := range branches {
+		if !hasObject(hash) {
+			wants = append(wants, hash)
+		}
+	}
+	if len(wants) == 0 {
+		return
+	}
+
+	if err := transport.fetchObjects(wants, localRefTips()); err != nil {
+		exitWithError(err)
+	}
+}
+
+func pushCase(consoleArgs []string) {
+	force := false
+	var positional []string
+	for _, arg := range consoleArgs[2:] {
+		if arg == common.FORCE_FLAG {
+			force = true
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	remote := common.DEFAULT_REMOTE
+	if len(positional) > 0 {
+		remote = positional[0]
+	}
+	branch := currentBranch()
+	if len(positional) > 1 {
+		branch = positional[1]
+	}
+	if branch == "" {
+		fmt.Println(notOnBranch)
+		return
+	}
+
+	localHash := readRef(common.HEADS_DIR + "/" + branch)
+	if localHash == "" {
+		fmt.Printf(noSuchLocalBranch, branch)
+		return
+	}
+
+	url, transport := openRemote(remote)
+	advertisement, err := transport.advertisedRefs()
+	if err != nil {
+		exitWithError(err)
+	}
+	requireSameHash(advertisement)
+	remoteHash := advertisement.branches[branch]
+	if remoteHash == localHash {
+		fmt.Println(everythingUpToDate)
+		return
+	}
+
+	// Only a descendant of the remote tip can replace it unless the push is forced
+	if remoteHash != "" && !isAncestor(remoteHash, localHash) && !force {
+		rejectPush(url, branch)
+	}
+
+	err = transport.pushBranch(branch, remoteHash, localHash, force, advertisedTips(advertisement))
+	switch err {
+	case nil:
+	case errNonFastForward, errStaleRef:
+		rejectPush(url, branch)
+	case errCheckedOutBranch:
+		// Moving the branch under someone's checked out files would leave their work tree stale
+		fmt.Printf(refusingCheckedOutBranch, branch)
+		os.Exit(1)
+	default:
+		exitWithError(err)
+	}
+	moveRef(remoteRefName(remote, branch), localHash, "update by push")
+
+	fmt.Printf(pushTo, url)
+	printRefUpdate(remoteHash, localHash, branch, branch)
+}
+
+func rejectPush(url string, branch string) {
+	fmt.Printf(pushTo, url)
+	fmt.Printf(refRejected, branch, branch)
+	fmt.Println(pushRejectedHint)
+	os.Exit(1)
+}
+
+func pullCase(consoleArgs []string) {
+	requireWorkTree()
+
+	remote := common.DEFAULT_REMOTE
+	if len(consoleArgs) > 2 {
+		remote = consoleArgs[2]
+	}
+	branch := currentBranch()
+	if len(consoleArgs) > 3 {
+		branch = consoleArgs[3]
+	}
+	if branch == "" {
+		fmt.Println(notOnBranch)
+		return
+	}
+
+	fetchRemote(remote)
+
+	theirs := readRef(remoteRefName(remote, branch))
+	if theirs == "" {
+		fmt.Printf(noSuchRemoteBranch, branch, remote)
+		return
+	}
+
+	url := getConfig(fmt.Sprintf(common.REMOTE_KEY, remote))
+	mergeCommit(theirs, remote+"/"+branch, fmt.Sprintf(pullMergeMessage, branch, url))
+}
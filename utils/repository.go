@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"version_control_go/common"
+)
+
+const initializedRepository = "Initialized empty SVCS repository in %s\n"
+const initializedBareRepository = "Initialized empty bare SVCS repository in %s\n"
+const reinitializedRepository = "Reinitialized existing SVCS repository in %s\n"
+const outsideRepository = "'%s' is outside repository at '%s'."
+const unsupportedHash = "Unsupported hash algorithm '%s'.\n"
+const hashCanNotChange = "The repository in %s already names its objects with %s.\n"
+
+var errNotARepository = errors.New("not a SVCS repository")
+
+// repoDirOverride is set from --repo-dir or SVCS_DIR before the repository is opened
+var repoDirOverride string
+
+// repoDir is the absolute path of the opened vcs directory and workTreeDir the directory holding it
+var repoDir string
+var workTreeDir string
+
+// parseGlobalOptions strips options that come before the command and returns the remaining arguments
+func parseGlobalOptions(args []string) []string {
+	repoDirOverride = os.Getenv(common.REPO_DIR_ENV)
+
+	remaining := []string{args[0]}
+	i := 1
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == common.REPO_DIR_FLAG {
+			if i+1 >= len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, arg)
+				os.Exit(1)
+			}
+			repoDirOverride = args[i+1]
+			i++
+		} else if strings.HasPrefix(arg, common.REPO_DIR_FLAG+"=") {
+			repoDirOverride = strings.TrimPrefix(arg, common.REPO_DIR_FLAG+"=")
+		} else {
+			break
+		}
+	}
+
+	return append(remaining, args[i:]...)
+}
+
+// findRepoDir walks up from startDir until it finds a directory containing a vcs repository or a bare repository;
+// a vcs directory without one, such as a source package of that name, is walked past
+func findRepoDir(startDir string) (string, error) {
+	dir := startDir
+	for {
+		candidate := filepath.Join(dir, common.VCS_DIR_NAME)
+		if isRepoDir(candidate) {
+			return candidate, nil
+		}
+		if isBareRepoDir(dir) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errNotARepository
+		}
+		dir = parent
+	}
+}
+
+// locateRepoDir returns the vcs directory to use, honouring the overrides before discovery
+func locateRepoDir() (string, error) {
+	if repoDirOverride != "" {
+		dir, err := filepath.Abs(repoDirOverride)
+		if err != nil {
+			return "", err
+		}
+		if !isRepoDir(dir) {
+			return "", errNotARepository
+		}
+		return dir, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return findRepoDir(cwd)
+}
+
+// isRepoDir tells whether dir holds a repository: a HEAD or the bare layout. A repository made before HEAD
+// existed gets one from running init in it again
+func isRepoDir(dir string) bool {
+	return fileExists(filepath.Join(dir, common.HEAD_FILE_NAME)) || isBareRepoDir(dir)
+}
+
+func isBareRepoDir(dir string) bool {
+	config := readConfigFile(filepath.Join(dir, common.CONFIG_FILE_NAME))
+	return config[common.BARE_KEY] == "true" && isDir(filepath.Join(dir, common.OBJECTS_DIR_NAME))
+}
+
+// openRepository sets repoDir and workTreeDir or exits if there is no repository
+func openRepository() {
+	dir, err := locateRepoDir()
+	if err != nil {
+		cwd, _ := os.Getwd()
+		if repoDirOverride != "" {
+			cwd = repoDirOverride
+		}
+		fmt.Fprintf(os.Stderr, common.NOT_A_REPOSITORY, cwd)
+		os.Exit(1)
+	}
+
+	repoDir = dir
+	workTreeDir = ""
+	if !isBareRepoDir(dir) {
+		workTreeDir = filepath.Dir(dir)
+	}
+}
+
+// requireWorkTree exits for commands that need checked out files when the repository is bare
+func requireWorkTree() {
+	if workTreeDir == "" {
+		fmt.Fprintln(os.Stderr, common.NOT_A_WORK_TREE)
+		os.Exit(1)
+	}
+}
+
+// vcsPath joins elements onto the opened vcs directory
+func vcsPath(elem ...string) string {
+	return filepath.Join(append([]string{repoDir}, elem...)...)
+}
+
+// workTreePath converts a path given on the command line into one relative to the work tree
+func workTreePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	relPath, err := filepath.Rel(workTreeDir, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(outsideRepository, path, workTreeDir)
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// initCase creates "<dir>/vcs", or with --bare a repository without a work tree directly in dir;
+// --hash picks the algorithm objects are named with
+func initCase(consoleArgs []string) {
+	bare := false
+	dir := ""
+	hashName := ""
+	args := consoleArgs[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case common.BARE_FLAG:
+			bare = true
+		case common.HASH_FLAG:
+			if i+1 == len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, common.HASH_FLAG)
+				return
+			}
+			i++
+			hashName = args[i]
+		default:
+			dir = args[i]
+		}
+	}
+
+	if _, err := hasherByName(hashName); hashName != "" && err != nil {
+		fmt.Printf(unsupportedHash, hashName)
+		return
+	}
+
+	// An explicit --repo-dir or SVCS_DIR names the repository directory itself
+	var targetDir string
+	switch {
+	case dir == "" && repoDirOverride != "":
+		targetDir = repoDirOverride
+	case bare:
+		targetDir = dir
+		if targetDir == "" {
+			targetDir = "."
+		}
+	default:
+		targetDir = filepath.Join(dir, common.VCS_DIR_NAME)
+	}
+
+	absDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	message := initializedRepository
+	if bare {
+		message = initializedBareRepository
+	}
+	if fileExists(filepath.Join(absDir, common.HEAD_FILE_NAME)) {
+		message = reinitializedRepository
+	}
+	if hashName != "" && hasObjectsIn(absDir) {
+		if existing := hashNameIn(absDir); hashName != existing {
+			fmt.Printf(hashCanNotChange, absDir, existing)
+			return
+		}
+	}
+
+	initRepoDir(absDir, bare, hashName)
+	fmt.Printf(message, absDir)
+}
+
+// initRepoDir seeds the layout of a repository, keeping anything that already exists; the hash algorithm, the
+// default when hashName is "", can only be recorded until the first object is named by it
+func initRepoDir(dir string, bare bool, hashName string) {
+	createDir(filepath.Join(dir, common.OBJECTS_DIR_NAME))
+	createDir(filepath.Join(dir, filepath.FromSlash(common.HEADS_DIR)))
+
+	configFilePath := filepath.Join(dir, common.CONFIG_FILE_NAME)
+	config := readConfigFile(configFilePath)
+	if !hasObjectsIn(dir) && (hashName != "" || config[common.HASH_KEY] == "") {
+		if hashName == "" {
+			hashName = defaultHashName
+		}
+		config[common.HASH_KEY] = hashName
+	}
+	if !fileExists(filepath.Join(dir, common.HEAD_FILE_NAME)) {
+		writeHeadFile(dir, symbolicRefPrefix+common.HEADS_DIR+"/"+common.DEFAULT_BRANCH)
+	}
+	config[common.BARE_KEY] = strconv.FormatBool(bare)
+	writeConfigFile(configFilePath, config)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func createDir(path string) {
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
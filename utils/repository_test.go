@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+func TestFindRepoDir(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are created under the test directory, a name ending in "/" is a directory
+		files map[string]string
+		start string
+		// want is the repository found, relative to the test directory, or "" for none
+		want string
+	}{
+		{name: "work tree", files: map[string]string{"vcs/HEAD": ""}, start: ".", want: "vcs"},
+		{name: "from a subdirectory", files: map[string]string{"vcs/HEAD": "", "a/b/": ""}, start: "a/b", want: "vcs"},
+		{name: "source package named vcs", files: map[string]string{"vcs/main.go": "package vcs\n"}, start: "vcs"},
+		{name: "lone config", files: map[string]string{"vcs/" + common.CONFIG_FILE_NAME: "user=tester\n"}, start: "."},
+		{name: "lone index", files: map[string]string{"vcs/" + common.INDEX_FILE_NAME: ""}, start: "."},
+		{name: "package below a repository", files: map[string]string{"vcs/HEAD": "", "src/vcs/main.go": "package vcs\n"}, start: "src", want: "vcs"},
+		{name: "bare", files: map[string]string{"bare/objects/": "", "bare/" + common.CONFIG_FILE_NAME: common.BARE_KEY + "=true\n"},
+			start: "bare", want: "bare"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				if strings.HasSuffix(name, "/") {
+					if err := os.MkdirAll(path, 0755); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := findRepoDir(filepath.Join(root, filepath.FromSlash(test.start)))
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("findRepoDir() = %s, want no repository", got)
+				}
+				return
+			}
+			if want := filepath.Join(root, test.want); err != nil || got != want {
+				t.Errorf("findRepoDir() = %s, %v, want %s", got, err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"version_control_go/common"
+)
+
+const revertIsEmpty = "Reverting %s changes nothing, it was already undone.\n"
+const couldNotRevert = "Could not revert %s... %s\nAfter resolving the conflicts, mark the paths with 'add <path>' and run 'commit'," +
+	" or use 'revert --skip' or 'revert --abort'.\n"
+
+// revertCase commits the inverse of a commit's changes on top of HEAD so history is never rewritten
+func revertCase(consoleArgs []string) {
+	requireWorkTree()
+
+	if len(consoleArgs) < 3 {
+		fmt.Println(revisionWasNotPassed)
+		return
+	}
+	if consoleArgs[2] == common.ABORT_FLAG || consoleArgs[2] == common.SKIP_FLAG {
+		stopPick(common.REVERT, common.REVERT_HEAD_FILE_NAME, consoleArgs[2])
+		return
+	}
+	hash, err := resolveRevision(consoleArgs[2])
+	if err != nil {
+		fmt.Println(commitDoesNotExist)
+		return
+	}
+	commit, err := readCommit(hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(commit.parents) > 1 {
+		fmt.Printf(commitIsAMerge, shortHash(hash))
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+
+	// Applying the change from the commit back to its parent undoes it; a root commit goes back to no files
+	parent := ""
+	if len(commit.parents) == 1 {
+		parent = commit.parents[0]
+	}
+	subject := commitSubject(commit)
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject, hash)
+	result := applyCommitChanges(hash, parent, "parent of "+shortHash(hash)+" ("+subject+")")
+
+	if len(result.conflicts) > 0 {
+		writeStateFile(common.REVERT_HEAD_FILE_NAME, hash)
+		writeStateFile(common.MERGE_MSG_FILE_NAME, message)
+		fmt.Printf(couldNotRevert, shortHash(hash), subject)
+		return
+	}
+
+	author := newSignature(getConfig(common.USER_NAME_KEY))
+	newHash, ok := commitPickedTree(result.entries, author, message, "revert: Revert \""+subject+"\"")
+	if !ok {
+		fmt.Printf(revertIsEmpty, shortHash(hash))
+		return
+	}
+	fmt.Printf(pickedCommit, headLabel(), shortHash(newHash), "Revert \""+subject+"\"")
+}
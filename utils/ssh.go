@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"version_control_go/common"
+)
+
+const stdioRefs = "refs"
+const stdioFetch = "fetch"
+const stdioPush = "push"
+const stdioOk = "ok"
+const stdioError = "error "
+
+const defaultSSHCommand = "ssh"
+const remoteProgram = "svcs"
+
+// sshTransport runs "serve --stdio" on another machine through the command in SVCS_SSH
+type sshTransport struct {
+	host string
+	port string
+	path string
+}
+
+func newSSHTransport(rawURL string) (remoteTransport, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Hostname()
+	if parsed.User != nil {
+		host = parsed.User.Username() + "@" + host
+	}
+	// ssh would read a host starting with "-" as one of its options, some of which run local commands
+	if parsed.Hostname() == "" || parsed.Path == "" || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("'%s' is not a valid ssh remote", rawURL)
+	}
+	return sshTransport{host: host, port: parsed.Port(), path: parsed.Path}, nil
+}
+
+// command builds "<SVCS_SSH> [-p port] -- host svcs --repo-dir '<path>' serve --stdio"; ssh hands the words after
+// the host to the remote shell as one command line, so the path is quoted for it
+func (t sshTransport) command() *exec.Cmd {
+	sshCommand := strings.Fields(os.Getenv(common.SSH_COMMAND_ENV))
+	if len(sshCommand) == 0 {
+		sshCommand = []string{defaultSSHCommand}
+	}
+
+	args := append([]string(nil), sshCommand[1:]...)
+	if t.port != "" {
+		args = append(args, "-p", t.port)
+	}
+	args = append(args, "--", t.host, remoteProgram, common.REPO_DIR_FLAG, shellQuote(t.path), common.SERVE, common.STDIO_FLAG)
+
+	cmd := exec.Command(sshCommand[0], args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// shellQuote makes a word a POSIX shell reads back unchanged
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// exchange sends one operation with its request and returns the reply once the server has exited
+func (t sshTransport) exchange(operation string, request []byte) (*bufio.Reader, error) {
+	var output bytes.Buffer
+	cmd := t.command()
+	cmd.Stdin = io.MultiReader(strings.NewReader(operation+"\n"), bytes.NewReader(request))
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	reply := bufio.NewReader(&output)
+	status, err := readLine(reply)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(status, stdioError) {
+		return nil, pushErrorFromMessage(strings.TrimPrefix(status, stdioError))
+	}
+	if status != stdioOk {
+		return nil, errMalformedRequest
+	}
+	return reply, nil
+}
+
+func (t sshTransport) advertisedRefs() (refAdvertisement, error) {
+	reply, err := t.exchange(stdioRefs, nil)
+	if err != nil {
+		return refAdvertisement{}, err
+	}
+	return readRefAdvertisement(reply)
+}
+
+func (t sshTransport) fetchObjects(wants []string, haves []string) error {
+	var request bytes.Buffer
+	if err := writeFetchRequest(&request, wants, haves); err != nil {
+		return err
+	}
+
+	reply, err := t.exchange(stdioFetch, request.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = readObjectStream(reply)
+	return err
+}
+
+func (t sshTransport) pushBranch(branch string, oldHash string, newHash string, force bool, haves []string) error {
+	var request bytes.Buffer
+	if err := writePushRequest(&request, branch, oldHash, newHash, force, haves); err != nil {
+		return err
+	}
+
+	_, err := t.exchange(stdioPush, request.Bytes())
+	return err
+}
+
+// serveStdio answers a single operation read from r; the status line tells the client whether a reply follows,
+// so only failures to talk to the client are returned
+func serveStdio(r io.Reader, w io.Writer) error {
+	request := bufio.NewReader(r)
+	operation, err := readLine(request)
+	if err != nil {
+		return err
+	}
+
+	var reply bytes.Buffer
+	switch operation {
+	case stdioRefs:
+		err = writeRefAdvertisement(&reply)
+	case stdioFetch:
+		err = serveFetch(request, &reply)
+	case stdioPush:
+		err = servePush(request)
+	default:
+		err = errMalformedRequest
+	}
+
+	if err != nil {
+		_, err = fmt.Fprintf(w, "%s%s\n", stdioError, err)
+		return err
+	}
+	if _, err := fmt.Fprintln(w, stdioOk); err != nil {
+		return err
+	}
+	_, err = reply.WriteTo(w)
+	return err
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+const stdioHelperEnv = "SVCS_TEST_STDIO_HELPER"
+
+// TestStdioHelper is not a test: run through SVCS_SSH it plays the remote end, reading the arguments ssh would
+// get and serving the repository they name on stdin and stdout
+func TestStdioHelper(t *testing.T) {
+	if os.Getenv(stdioHelperEnv) == "" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != remoteProgram {
+		args = args[1:]
+	}
+	// Like ssh, join the words into one command line and let the "remote shell" split it
+	words, ok := splitShellWords(strings.Join(args, " "))
+	if !ok {
+		os.Exit(1)
+	}
+	consoleArgs := parseGlobalOptions(words)
+	openRepository()
+	CommandSwitchCases(consoleArgs[1], common.Commands[consoleArgs[1]], consoleArgs)
+	os.Exit(0)
+}
+
+// splitShellWords splits a command line into words the way a POSIX shell does for plain, single quoted and
+// backslash escaped words, refusing anything else a shell would give meaning to
+func splitShellWords(line string) ([]string, bool) {
+	var words []string
+	var word strings.Builder
+	inWord, quoted, escaped := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && !quoted:
+			escaped, inWord = true, true
+		case quoted && r == '\'':
+			quoted = false
+		case quoted:
+			word.WriteRune(r)
+		case r == '\'':
+			quoted, inWord = true, true
+		case r == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case strings.ContainsRune("\"\\$`;&|<>()*?[#~{}\t\n", r):
+			return nil, false
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted || escaped {
+		return nil, false
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, true
+}
+
+func TestSSHRemoteURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantErr  bool
+		wantHost string
+	}{
+		{url: "ssh://example.com/srv/repo", wantHost: "example.com"},
+		{url: "ssh://me@example.com:2222/srv/repo", wantHost: "me@example.com"},
+		{url: "ssh://-oProxyCommand=id/x", wantErr: true},
+		{url: "ssh://-oProxyCommand=id@example.com/x", wantErr: true},
+		{url: "ssh://example.com", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			transport, err := newSSHTransport(test.url)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("newSSHTransport() = %v, want an error", transport)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := transport.(sshTransport).command().Args
+			for i, arg := range args {
+				if arg == test.wantHost {
+					if i == 0 || args[i-1] != "--" {
+						t.Errorf("the host is not after \"--\" in %q", args)
+					}
+					return
+				}
+			}
+			t.Errorf("%q has no host %s", args, test.wantHost)
+		})
+	}
+}
+
+// TestSSHTransportQuotesPath pushes to a repository whose path a remote shell would otherwise run commands from
+func TestSSHTransportQuotesPath(t *testing.T) {
+	t.Setenv(stdioHelperEnv, "1")
+	t.Setenv(common.SSH_COMMAND_ENV, os.Args[0]+" -test.run=^TestStdioHelper$ --")
+
+	remote := testRepo{dir: filepath.Join(t.TempDir(), "it's a;repo")}
+	initRepoDir(remote.dir, true, "")
+	local := newTestRepo(t)
+	first := local.commitFiles(t, "first", map[string]string{"f": "1\n"})
+
+	transport, err := openTransport("ssh://example.com" + filepath.ToSlash(remote.dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.pushBranch(common.DEFAULT_BRANCH, "", first, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRefIn(remote.dir, common.HEADS_DIR+"/"+common.DEFAULT_BRANCH); got != first {
+		t.Errorf("remote master = %s, want %s", got, first)
+	}
+}
+
+func TestSSHTransport(t *testing.T) {
+	t.Setenv(stdioHelperEnv, "1")
+	t.Setenv(common.SSH_COMMAND_ENV, os.Args[0]+" -test.run=^TestStdioHelper$ --")
+
+	remote := newBareTestRepo(t)
+	local := newTestRepo(t)
+	first, second, _, other := pushTestHistory(t, local)
+	url := "ssh://example.com" + filepath.ToSlash(remote.dir)
+
+	transport, err := openTransport(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		oldHash, newHash string
+		wantErr          string
+		wantRef          string
+	}{
+		{name: "new branch", newHash: first, wantRef: first},
+		{name: "fast-forward", oldHash: first, newHash: second, wantRef: second},
+		{name: "non-fast-forward", oldHash: second, newHash: other, wantErr: errNonFastForward.Error(), wantRef: second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advertisement, err := transport.advertisedRefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if advertisement.branches[common.DEFAULT_BRANCH] != test.oldHash {
+				t.Fatalf("remote advertised %s, want %s", advertisement.branches[common.DEFAULT_BRANCH], test.oldHash)
+			}
+
+			err = transport.pushBranch(common.DEFAULT_BRANCH, test.oldHash, test.newHash, false, advertisedTips(advertisement))
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatalf("pushBranch() = %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Fatalf("pushBranch() = %v, want an error with %q", err, test.wantErr)
+			}
+			if got := readRefIn(remote.dir, common.HEADS_DIR+"/"+common.DEFAULT_BRANCH); got != test.wantRef {
+				t.Errorf("remote master = %s, want %s", got, test.wantRef)
+			}
+		})
+	}
+
+	// Fetching into an empty repository brings back everything the branch reaches
+	receiver := newBareTestRepo(t)
+	restore := receiver.use()
+	defer restore()
+	if err := transport.fetchObjects([]string{second}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConnectivity(second); err != nil {
+		t.Error(err)
+	}
+}
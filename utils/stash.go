@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"version_control_go/common"
+)
+
+const stashPushCommand = "push"
+const stashListCommand = "list"
+const stashShowCommand = "show"
+const stashApplyCommand = "apply"
+const stashPopCommand = "pop"
+const stashDropCommand = "drop"
+
+const stashSaved = "Saved working directory and index state %s\n"
+const noLocalChangesToSave = "No local changes to save."
+const noInitialCommit = "You do not have the initial commit yet."
+const noStashEntries = "No stash entries found."
+const notAStashEntry = "'%s' is not a valid stash entry.\n"
+const stashLine = "stash@{%d}: %s\n"
+const stashChange = "%s %s\n"
+const stashDropped = "Dropped stash@{%d} (%s)\n"
+const stashKept = "The stash entry is kept in case you need it again."
+const unknownStashCommand = "Unknown stash command '%s'.\n"
+
+const stashOursLabel = "Updated upstream"
+const stashTheirsLabel = "Stashed changes"
+
+// stashCase saves the index and tracked files as commits under refs/stash, whose reflog keeps older entries
+func stashCase(consoleArgs []string) {
+	requireWorkTree()
+
+	subcommand := stashPushCommand
+	args := consoleArgs[2:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+
+	switch subcommand {
+	case stashPushCommand:
+		stashPush(args)
+	case stashListCommand:
+		stashList()
+	case stashShowCommand:
+		stashShow(args)
+	case stashApplyCommand:
+		stashApply(args, false)
+	case stashPopCommand:
+		stashApply(args, true)
+	case stashDropCommand:
+		stashDrop(args)
+	default:
+		fmt.Printf(unknownStashCommand, subcommand)
+	}
+}
+
+// stashPush records the index as a commit on HEAD and the tracked files as a commit with HEAD and the index
+// commit as parents, then puts the work tree back to HEAD
+func stashPush(args []string) {
+	message := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == common.MESSAGE_FLAG {
+			if i+1 == len(args) {
+				fmt.Printf(common.OPTION_REQUIRES_VALUE, common.MESSAGE_FLAG)
+				return
+			}
+			i++
+			message = args[i]
+		}
+	}
+
+	head := headCommit()
+	if head == "" {
+		fmt.Println(noInitialCommit)
+		return
+	}
+	if !hasLocalChanges() {
+		fmt.Println(noLocalChangesToSave)
+		return
+	}
+
+	headObject, err := readCommit(head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	branch := currentBranch()
+	if branch == "" {
+		branch = "(no branch)"
+	}
+	subject := shortHash(head) + " " + strings.SplitN(headObject.message, "\n", 2)[0]
+	if message == "" {
+		message = "WIP on " + branch + ": " + subject
+	} else {
+		message = "On " + branch + ": " + message
+	}
+
+	entries := fillLegacyIndexEntries(readIndex())
+	var workEntries []treeEntry
+	for _, entry := range entries {
+		data, err := os.ReadFile(workTreeFile(entry.path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		workEntries = append(workEntries, treeEntry{hash: writeBlob(data), path: entry.path})
+	}
+
+	author := newSignature(getConfig(common.USER_NAME_KEY))
+	indexCommit := writeCommit(commitObject{
+		tree:      writeTree(entries),
+		parents:   []string{head},
+		author:    author,
+		committer: author,
+		message:   "index on " + branch + ": " + subject,
+	})
+	stashCommit := writeCommit(commitObject{
+		tree:      writeTree(workEntries),
+		parents:   []string{head, indexCommit},
+		author:    author,
+		committer: author,
+		message:   message,
+	})
+	moveRef(common.STASH_REF, stashCommit, message)
+
+	checkoutCommit(head)
+	fmt.Printf(stashSaved, message)
+}
+
+// stashEntry finds the entry named by "stash@{n}" or "n" in args, the newest one by default
+func stashEntry(args []string) (int, reflogEntry, bool) {
+	entries := readReflog(common.STASH_REF)
+	if len(entries) == 0 {
+		fmt.Println(noStashEntries)
+		return 0, reflogEntry{}, false
+	}
+	if len(args) == 0 {
+		return 0, entries[len(entries)-1], true
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(args[0], common.STASH+"@{"), "}")
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 || n >= len(entries) {
+		fmt.Printf(notAStashEntry, args[0])
+		return 0, reflogEntry{}, false
+	}
+	return n, entries[len(entries)-1-n], true
+}
+
+func stashList() {
+	entries := readReflog(common.STASH_REF)
+	for i := len(entries) - 1; i >= 0; i-- {
+		fmt.Printf(stashLine, len(entries)-1-i, entries[i].message)
+	}
+}
+
+// stashShow lists the files a stash entry changed against the commit it was made on
+func stashShow(args []string) {
+	_, entry, ok := stashEntry(args)
+	if !ok {
+		return
+	}
+	stash, err := readCommit(entry.newHash)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	before := entryHashes(treeEntriesOfCommit(stash.parents[0]))
+	after := entryHashes(treeEntriesOfCommit(entry.newHash))
+	var paths []string
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		switch {
+		case before[path] == "":
+			fmt.Printf(stashChange, "A", path)
+		case after[path] == "":
+			fmt.Printf(stashChange, "D", path)
+		case before[path] != after[path]:
+			fmt.Printf(stashChange, "M", path)
+		}
+	}
+}
+
+// stashApply merges a stash entry into the work tree; pop also drops it once it applied cleanly
+func stashApply(args []string, pop bool) {
+	n, entry, ok := stashEntry(args)
+	if !ok {
+		return
+	}
+	if hasLocalChanges() {
+		fmt.Println(localChangesWouldBeOverwritten)
+		return
+	}
+
+	stash, err := readCommit(entry.newHash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	base, indexCommit := stash.parents[0], stash.parents[1]
+	head := headCommit()
+
+	result := mergeTrees(treeEntriesOfCommit(base), treeEntriesOfCommit(head), treeEntriesOfCommit(entry.newHash), stashOursLabel, stashTheirsLabel)
+	applyMergeResult(result)
+	if len(result.conflicts) > 0 {
+		if pop {
+			fmt.Println(stashKept)
+		}
+		return
+	}
+
+	if head == base {
+		// Nothing moved since the stash was made, so the index comes back exactly as it was
+		writeIndex(treeEntriesOfCommit(indexCommit))
+	} else {
+		// On another commit the changes are left unstaged, only new files are added so they stay tracked
+		index := treeEntriesOfCommit(head)
+		headHashes := entryHashes(index)
+		for _, entry := range result.entries {
+			if _, tracked := headHashes[entry.path]; !tracked {
+				index = append(index, entry)
+			}
+		}
+		writeIndex(index)
+	}
+
+	if pop {
+		dropStashEntry(n)
+	}
+}
+
+func stashDrop(args []string) {
+	n, _, ok := stashEntry(args)
+	if ok {
+		dropStashEntry(n)
+	}
+}
+
+// dropStashEntry removes an entry from the stash reflog and points refs/stash at the newest one left
+func dropStashEntry(n int) {
+	entries := readReflog(common.STASH_REF)
+	i := len(entries) - 1 - n
+	dropped := entries[i]
+	entries = append(entries[:i], entries[i+1:]...)
+	writeReflog(common.STASH_REF, entries)
+
+	if len(entries) == 0 {
+		removeFile(filepath.Join(repoDir, filepath.FromSlash(common.STASH_REF)))
+	} else {
+		writeRef(common.STASH_REF, entries[len(entries)-1].newHash)
+	}
+	fmt.Printf(stashDropped, n, dropped.newHash)
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// TestStashApply stashes staged and unstaged changes, optionally moves HEAD, and brings the stash back
+func TestStashApply(t *testing.T) {
+	tests := []struct {
+		name string
+		// staged are added before unstaged is written, both on top of a = "a", b = "b"
+		staged   map[string]string
+		unstaged map[string]string
+		// committed moves HEAD on after the stash was made
+		committed  map[string]string
+		subcommand string
+		wantFiles  map[string]string
+		// wantStaged are the paths whose index entry holds the work tree content, the rest stay as in HEAD
+		wantStaged   []string
+		wantConflict bool
+		wantEntries  int
+	}{
+		{
+			name:       "pop on the same commit",
+			staged:     map[string]string{"a": "staged a\n", "new": "new\n"},
+			unstaged:   map[string]string{"b": "changed b\n"},
+			subcommand: stashPopCommand,
+			wantFiles:  map[string]string{"a": "staged a\n", "b": "changed b\n", "new": "new\n"},
+			wantStaged: []string{"a", "new"},
+		},
+		{
+			name:        "apply keeps the entry",
+			unstaged:    map[string]string{"a": "changed a\n"},
+			subcommand:  stashApplyCommand,
+			wantFiles:   map[string]string{"a": "changed a\n", "b": "b\n"},
+			wantEntries: 1,
+		},
+		{
+			name:       "apply onto a moved HEAD",
+			staged:     map[string]string{"a": "staged a\n", "new": "new\n"},
+			committed:  map[string]string{"b": "committed b\n"},
+			subcommand: stashApplyCommand,
+			wantFiles:  map[string]string{"a": "staged a\n", "b": "committed b\n", "new": "new\n"},
+			// Only the new file is staged, so it stays tracked
+			wantStaged: []string{"new"}, wantEntries: 1,
+		},
+		{
+			name:         "pop conflicting with a moved HEAD",
+			unstaged:     map[string]string{"a": "stashed a\n"},
+			committed:    map[string]string{"a": "committed a\n"},
+			subcommand:   stashPopCommand,
+			wantFiles:    map[string]string{"b": "b\n"},
+			wantConflict: true, wantEntries: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			repo.commitFiles(t, "base", map[string]string{"a": "a\n", "b": "b\n"})
+			repo.writeFiles(t, test.staged)
+			for name := range test.staged {
+				runCommand(t, common.ADD, name)
+			}
+			repo.writeFiles(t, test.unstaged)
+
+			runCommand(t, common.STASH)
+			if hasLocalChanges() {
+				t.Fatal("stash left local changes")
+			}
+			if len(test.committed) > 0 {
+				repo.commitFiles(t, "moved", test.committed)
+			}
+
+			output := runCommand(t, common.STASH, test.subcommand)
+			for name, want := range test.wantFiles {
+				if got := repo.readWorkTreeFile(t, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if test.wantConflict {
+				if got := repo.readWorkTreeFile(t, "a"); !strings.Contains(got, "<<<<<<< "+stashOursLabel) {
+					t.Errorf("a = %q, want conflict markers", got)
+				}
+				if test.subcommand == stashPopCommand && !strings.Contains(output, stashKept) {
+					t.Errorf("pop printed %q, want it to keep the entry", output)
+				}
+			}
+
+			if !test.wantConflict {
+				headHashes := entryHashes(treeEntriesOfCommit(headCommit()))
+				staged := map[string]bool{}
+				for _, name := range test.wantStaged {
+					staged[name] = true
+				}
+				for path, hash := range entryHashes(readIndex()) {
+					want := headHashes[path]
+					if staged[path] {
+						want = hashBlob([]byte(repo.readWorkTreeFile(t, path)))
+					}
+					if hash != want {
+						t.Errorf("the index has %s staged as %s, want %s", path, hash, want)
+					}
+				}
+				for path := range staged {
+					if _, ok := entryHashes(readIndex())[path]; !ok {
+						t.Errorf("%s is not in the index", path)
+					}
+				}
+			}
+			if got := len(readReflog(common.STASH_REF)); got != test.wantEntries {
+				t.Errorf("%d stash entries left, want %d", got, test.wantEntries)
+			}
+		})
+	}
+}
+
+func TestStashListAndDrop(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commitFiles(t, "base", map[string]string{"a": "a\n"})
+	if output := runCommand(t, common.STASH); !strings.Contains(output, noLocalChangesToSave) {
+		t.Errorf("stash without changes printed %q", output)
+	}
+
+	for _, message := range []string{"first", "second", "third"} {
+		repo.writeFiles(t, map[string]string{"a": message + "\n"})
+		runCommand(t, common.STASH, stashPushCommand, common.MESSAGE_FLAG, message)
+	}
+	want := "stash@{0}: On master: third\nstash@{1}: On master: second\nstash@{2}: On master: first\n"
+	if got := runCommand(t, common.STASH, stashListCommand); got != want {
+		t.Errorf("list printed %q, want %q", got, want)
+	}
+
+	runCommand(t, common.STASH, stashDropCommand, "stash@{1}")
+	want = "stash@{0}: On master: third\nstash@{1}: On master: first\n"
+	if got := runCommand(t, common.STASH, stashListCommand); got != want {
+		t.Errorf("list after drop printed %q, want %q", got, want)
+	}
+
+	runCommand(t, common.STASH, stashPopCommand, "stash@{1}")
+	if got := repo.readWorkTreeFile(t, "a"); got != "first\n" {
+		t.Errorf("popping stash@{1} gave a = %q, want the first stash", got)
+	}
+	want = "stash@{0}: On master: third\n"
+	if got := runCommand(t, common.STASH, stashListCommand); got != want {
+		t.Errorf("list after pop printed %q, want %q", got, want)
+	}
+	if got := readRef(common.STASH_REF); got != readReflog(common.STASH_REF)[0].newHash {
+		t.Errorf("refs/stash = %s, want the entry left", got)
+	}
+}
@@ -0,0 +1,547 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"version_control_go/common"
+)
+
+const objectStreamEnd = "end"
+const fetchWant = "want"
+const fetchHave = "have"
+const fetchDone = "done"
+const pushUpdate = "update"
+const noHash = "-"
+const hashAdvertisement = "hash"
+
+// maxTransferObjectSize caps an object in a stream, compressed and inflated, so a peer cannot make the other end
+// allocate whatever length it claims
+const maxTransferObjectSize = 256 << 20
+
+var errNonFastForward = errors.New("non-fast-forward")
+var errStaleRef = errors.New("remote branch changed since it was read")
+var errCheckedOutBranch = errors.New("branch is checked out in the remote work tree")
+var errMalformedRequest = errors.New("malformed request")
+var errNoHashAdvertised = errors.New("the remote did not say which hash algorithm names its objects")
+var errObjectTooLarge = errors.New("object is too large to transfer")
+
+const missingPushedObject = "object %s is missing, the pushed history is incomplete"
+const hashAlgorithmsDiffer = "The remote names objects with %s and this repository with %s.\n"
+
+// refAdvertisement is what a remote tells about itself before any objects are exchanged
+type refAdvertisement struct {
+	branches map[string]string
+	head     string
+	hashName string
+}
+
+// remoteTransport is how fetch, push and clone talk to another repository
+type remoteTransport interface {
+	// advertisedRefs returns the branches of the remote, the branch its HEAD points at and its hash algorithm
+	advertisedRefs() (refAdvertisement, error)
+	// fetchObjects stores locally what is reachable from wants and not from haves
+	fetchObjects(wants []string, haves []string) error
+	// pushBranch sends what newHash reaches beyond haves, the commits the remote advertised, and moves branch from
+	// oldHash to newHash
+	pushBranch(branch string, oldHash string, newHash string, force bool, haves []string) error
+}
+
+// openTransport picks the transport from the form of the remote location
+func openTransport(url string) (remoteTransport, error) {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return httpTransport{baseURL: strings.TrimSuffix(url, "/")}, nil
+	}
+	if strings.HasPrefix(url, "ssh://") {
+		return newSSHTransport(url)
+	}
+
+	dir, err := repoDirAt(url)
+	if err != nil {
+		return nil, err
+	}
+	return fileTransport{dir: dir}, nil
+}
+
+// fileTransport reaches a repository on a local or mounted filesystem
+type fileTransport struct {
+	dir string
+}
+
+func (t fileTransport) advertisedRefs() (refAdvertisement, error) {
+	return refAdvertisement{
+		branches: listRefsIn(t.dir, common.HEADS_DIR),
+		head:     headBranchIn(t.dir),
+		hashName: hashNameIn(t.dir),
+	}, nil
+}
+
+func (t fileTransport) fetchObjects(wants []string, haves []string) error {
+	copyObjects(t.dir, repoDir)
+	return nil
+}
+
+func (t fileTransport) pushBranch(branch string, oldHash string, newHash string, force bool, haves []string) error {
+	if readRefIn(t.dir, common.HEADS_DIR+"/"+branch) != oldHash {
+		return errStaleRef
+	}
+	if !isBareRepoDir(t.dir) && headBranchIn(t.dir) == branch {
+		return errCheckedOutBranch
+	}
+
+	copyObjects(repoDir, t.dir)
+	return updateRefIn(t.dir, common.HEADS_DIR+"/"+branch, oldHash, newHash)
+}
+
+// localRefTips returns the commits every local and remote tracking branch points at
+func localRefTips() []string {
+	var tips []string
+	for _, prefix := range []string{common.HEADS_DIR, common.REMOTES_DIR} {
+		for _, hash := range listRefsIn(repoDir, prefix) {
+			tips = append(tips, hash)
+		}
+	}
+	return tips
+}
+
+// reachableObjects lists the commits, trees and blobs reachable from tips that aren't in exclude
+func reachableObjects(tips []string, exclude map[string]bool) []string {
+	var result []string
+	seen := map[string]bool{}
+	queue := append([]string(nil), tips...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if hash == "" || seen[hash] || exclude[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		// Haves the other side sent may be unknown here and are skipped
+		commit, err := readCommit(hash)
+		if err != nil {
+			continue
+		}
+		result = append(result, hash)
+		queue = append(queue, commit.parents...)
+
+		if seen[commit.tree] || exclude[commit.tree] {
+			continue
+		}
+		seen[commit.tree] = true
+		result = append(result, commit.tree)
+
+		entries, err := readTree(commit.tree)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range entries {
+			if !seen[entry.hash] && !exclude[entry.hash] {
+				seen[entry.hash] = true
+				result = append(result, entry.hash)
+			}
+		}
+	}
+	return result
+}
+
+// missingObjects lists what is reachable from wants but not from haves, and returns what haves reach as well
+func missingObjects(wants []string, haves []string) ([]string, map[string]bool) {
+	known := map[string]bool{}
+	for _, hash := range reachableObjects(haves, nil) {
+		known[hash] = true
+	}
+	return reachableObjects(wants, known), known
+}
+
+// advertisedTips returns the commits the branches of a remote point at, the objects it is known to have
+func advertisedTips(advertisement refAdvertisement) []string {
+	var tips []string
+	for _, name := range sortedKeys(advertisement.branches) {
+		tips = append(tips, advertisement.branches[name])
+	}
+	return tips
+}
+
+// writeObjectStream sends each object as "<hash> <type> <base> <length>\n" and that many bytes of zlib data,
+// followed by an end line. The data is the object itself, or with a base other than "-" a delta against another
+// version of the same path the receiver has or was sent before
+func writeObjectStream(w io.Writer, hashes []string, known map[string]bool) error {
+	sending := map[string]bool{}
+	for _, hash := range hashes {
+		sending[hash] = true
+	}
+	sent := map[string]bool{}
+	bases := map[string][]string{}
+
+	for _, hash := range hashes {
+		objectType, data, err := readObjectWithType(hash)
+		if err != nil {
+			return err
+		}
+		if objectType == commitType {
+			pairTransferBases(hash, sending, bases)
+		}
+
+		baseHash, payload := noHash, deflate(data)
+		for _, base := range bases[hash] {
+			if !known[base] && !sent[base] {
+				continue
+			}
+			if baseData, err := readObject(base); err == nil {
+				// A delta is only sent when it is smaller than the object itself
+				if delta := deflate(encodeDelta(baseData, data)); len(delta) < len(payload) {
+					baseHash, payload = base, delta
+				}
+			}
+			break
+		}
+
+		if _, err := fmt.Fprintf(w, "%s %s %s %d\n", hash, objectType, baseHash, len(payload)); err != nil {
+			return err
+		}
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+		sent[hash] = true
+	}
+	_, err := fmt.Fprintln(w, objectStreamEnd)
+	return err
+}
+
+// pairTransferBases pairs the blobs a commit changed with the versions of the same paths in its parents; each
+// of a pair that still has to be sent may go as a delta against the other, whichever the receiver gets first
+func pairTransferBases(hash string, sending map[string]bool, bases map[string][]string) {
+	commit, err := readCommit(hash)
+	if err != nil {
+		return
+	}
+	current := entryHashes(treeEntriesOfCommit(hash))
+	for _, parent := range commit.parents {
+		if _, err := readCommit(parent); err != nil {
+			continue
+		}
+		for path, parentBlob := range entryHashes(treeEntriesOfCommit(parent)) {
+			blob, ok := current[path]
+			if !ok || blob == parentBlob {
+				continue
+			}
+			if sending[blob] {
+				bases[blob] = append(bases[blob], parentBlob)
+			}
+			if sending[parentBlob] {
+				bases[parentBlob] = append(bases[parentBlob], blob)
+			}
+		}
+	}
+}
+
+// readObjectStream stores every object of a stream after checking its content matches its hash
+func readObjectStream(r *bufio.Reader) (int, error) {
+	count := 0
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return count, err
+		}
+		if line == objectStreamEnd {
+			return count, nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return count, errMalformedRequest
+		}
+		hash, objectType, baseHash := fields[0], fields[1], fields[2]
+		length, err := strconv.Atoi(fields[3])
+		if err != nil || length < 0 || !isHexHash(hash) || !isObjectType(objectType) ||
+			baseHash != noHash && !isHexHash(baseHash) {
+			return count, errMalformedRequest
+		}
+		if length > maxTransferObjectSize {
+			return count, fmt.Errorf("object %s: %w", hash, errObjectTooLarge)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return count, err
+		}
+		data, err := inflateTransferObject(payload)
+		if err != nil {
+			return count, fmt.Errorf("object %s: %w", hash, err)
+		}
+		if baseHash != noHash {
+			targetLength, err := deltaTargetLength(data)
+			if err != nil {
+				return count, fmt.Errorf("object %s: %w", hash, err)
+			}
+			if targetLength > maxTransferObjectSize {
+				return count, fmt.Errorf("object %s: %w", hash, errObjectTooLarge)
+			}
+			base, err := readObject(baseHash)
+			if err != nil {
+				return count, fmt.Errorf("delta base %s of %s: %w", baseHash, hash, err)
+			}
+			if data, err = applyDelta(base, data); err != nil {
+				return count, fmt.Errorf("object %s: %w", hash, err)
+			}
+		}
+		if hashObject(objectType, data) != hash {
+			return count, fmt.Errorf("object %s does not match its content", hash)
+		}
+		writeObject(hash, objectType, data)
+		count++
+	}
+}
+
+// inflateTransferObject inflates an object of a stream, refusing one that inflates beyond maxTransferObjectSize
+func inflateTransferObject(payload []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxTransferObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTransferObjectSize {
+		return nil, errObjectTooLarge
+	}
+	return data, nil
+}
+
+func isHexHash(hash string) bool {
+	return len(hash) > 2 && strings.Trim(hash, "0123456789abcdef") == ""
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeRefAdvertisement lists "HEAD <branch>", "hash <algorithm>" and then "<hash> <branch>" for every branch
+// of the served repository
+func writeRefAdvertisement(w io.Writer) error {
+	branches := listRefsIn(repoDir, common.HEADS_DIR)
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if _, err := fmt.Fprintf(w, "%s %s\n", common.HEAD_FILE_NAME, headBranchIn(repoDir)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%s %s\n", hashAdvertisement, repoHasher().Name()); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s %s\n", branches[name], name); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, objectStreamEnd)
+	return err
+}
+
+func readRefAdvertisement(r *bufio.Reader) (refAdvertisement, error) {
+	advertisement := refAdvertisement{branches: map[string]string{}}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return refAdvertisement{}, err
+		}
+		if line == objectStreamEnd && advertisement.hashName == "" {
+			return refAdvertisement{}, errNoHashAdvertised
+		}
+		if line == objectStreamEnd {
+			return advertisement, nil
+		}
+
+		first, second, _ := strings.Cut(line, " ")
+		if first == common.HEAD_FILE_NAME {
+			advertisement.head = second
+			continue
+		}
+		if first == hashAdvertisement {
+			advertisement.hashName = second
+			continue
+		}
+		if !isHexHash(first) || !isValidRefName(second) {
+			return refAdvertisement{}, errMalformedRequest
+		}
+		advertisement.branches[second] = first
+	}
+}
+
+// requireSameHash stops before objects named by another hash algorithm end up in this repository
+func requireSameHash(advertisement refAdvertisement) {
+	if local := repoHasher().Name(); advertisement.hashName != local {
+		fmt.Fprintf(os.Stderr, hashAlgorithmsDiffer, advertisement.hashName, local)
+		os.Exit(1)
+	}
+}
+
+func writeFetchRequest(w io.Writer, wants []string, haves []string) error {
+	for _, hash := range wants {
+		if _, err := fmt.Fprintf(w, "%s %s\n", fetchWant, hash); err != nil {
+			return err
+		}
+	}
+	for _, hash := range haves {
+		if _, err := fmt.Fprintf(w, "%s %s\n", fetchHave, hash); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, fetchDone)
+	return err
+}
+
+// serveFetch answers a want/have negotiation with the objects the client is missing
+func serveFetch(r *bufio.Reader, w io.Writer) error {
+	var wants, haves []string
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		if line == fetchDone {
+			break
+		}
+
+		kind, hash, _ := strings.Cut(line, " ")
+		if !isHexHash(hash) {
+			return errMalformedRequest
+		}
+		switch kind {
+		case fetchWant:
+			wants = append(wants, hash)
+		case fetchHave:
+			haves = append(haves, hash)
+		default:
+			return errMalformedRequest
+		}
+	}
+
+	hashes, known := missingObjects(wants, haves)
+	return writeObjectStream(w, hashes, known)
+}
+
+// writePushRequest asks to move branch from oldHash to newHash and sends what newHash reaches beyond haves
+func writePushRequest(w io.Writer, branch string, oldHash string, newHash string, force bool, haves []string) error {
+	if oldHash == "" {
+		oldHash = noHash
+	}
+	if _, err := fmt.Fprintf(w, "%s %s %s %s %t\n", pushUpdate, branch, oldHash, newHash, force); err != nil {
+		return err
+	}
+	hashes, known := missingObjects([]string{newHash}, haves)
+	return writeObjectStream(w, hashes, known)
+}
+
+// servePush stores the pushed objects and moves the branch if the update is still valid
+func servePush(r *bufio.Reader) error {
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 5 || fields[0] != pushUpdate || !isValidRefName(fields[1]) || !isHexHash(fields[3]) {
+		return errMalformedRequest
+	}
+	branch, oldHash, newHash, force := fields[1], fields[2], fields[3], fields[4] == "true"
+	if oldHash == noHash {
+		oldHash = ""
+	}
+
+	if _, err := readObjectStream(r); err != nil {
+		return err
+	}
+
+	if readRef(common.HEADS_DIR+"/"+branch) != oldHash {
+		return errStaleRef
+	}
+	if err := checkConnectivity(newHash); err != nil {
+		return err
+	}
+	if oldHash != "" && !force && !isAncestor(oldHash, newHash) {
+		return errNonFastForward
+	}
+	if workTreeDir != "" && currentBranch() == branch {
+		return errCheckedOutBranch
+	}
+
+	// The branch only moves if it still is where the checks above saw it
+	return updateRefIn(repoDir, common.HEADS_DIR+"/"+branch, oldHash, newHash)
+}
+
+// checkConnectivity makes sure every commit, tree and blob tip reaches is stored, so a branch never ends up
+// pointing at history the repository only has part of
+func checkConnectivity(tip string) error {
+	seen := map[string]bool{}
+	queue := []string{tip}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			return fmt.Errorf(missingPushedObject, hash)
+		}
+		queue = append(queue, commit.parents...)
+
+		if seen[commit.tree] {
+			continue
+		}
+		seen[commit.tree] = true
+		entries, err := readTree(commit.tree)
+		if err != nil {
+			return fmt.Errorf(missingPushedObject, commit.tree)
+		}
+		for _, entry := range entries {
+			if seen[entry.hash] {
+				continue
+			}
+			seen[entry.hash] = true
+			if !hasObject(entry.hash) {
+				return fmt.Errorf(missingPushedObject, entry.hash)
+			}
+		}
+	}
+	return nil
+}
+
+// pushErrorFromMessage maps an error a server reported back onto the matching local error
+func pushErrorFromMessage(message string) error {
+	for _, known := range []error{errNonFastForward, errStaleRef, errCheckedOutBranch} {
+		if strings.TrimSpace(message) == known.Error() {
+			return known
+		}
+	}
+	return errors.New(strings.TrimSpace(message))
+}
+
+// exitWithError reports an unexpected transport failure
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"version_control_go/common"
+)
+
+// serveTestRepo serves the repository over loopback HTTP and returns its URL; every request runs with the
+// repository open and its reply is buffered, so the client never runs while the server's repository is the open one
+func serveTestRepo(t *testing.T, repo testRepo, allowPush bool) string {
+	t.Helper()
+	handler := repositoryHandler(allowPush)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := httptest.NewRecorder()
+		restore := repo.use()
+		handler.ServeHTTP(reply, r)
+		restore()
+
+		for key, values := range reply.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(reply.Code)
+		w.Write(reply.Body.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// pushTestHistory makes first <- second <- third on master and other on top of first
+func pushTestHistory(t *testing.T, repo testRepo) (string, string, string, string) {
+	t.Helper()
+	first := repo.commitFiles(t, "first", map[string]string{"big": numberedLines(1, 500), "f": "1\n"})
+	second := repo.commitFiles(t, "second", map[string]string{"big": numberedLines(1, 500) + "501\n"})
+	third := repo.commitFiles(t, "third", map[string]string{"f": "3\n"})
+	runCommand(t, common.CHECKOUT, first)
+	other := repo.commitFiles(t, "other", map[string]string{"f": "other\n"})
+	runCommand(t, common.CHECKOUT, common.DEFAULT_BRANCH)
+	return first, second, third, other
+}
+
+func numberedLines(from int, to int) string {
+	var builder strings.Builder
+	for i := from; i <= to; i++ {
+		builder.WriteString(strings.Repeat("x", i%7) + "line\n")
+		builder.WriteString(string(rune('a'+i%26)) + "\n")
+	}
+	return builder.String()
+}
+
+func TestHTTPPush(t *testing.T) {
+	remote := newBareTestRepo(t)
+	url := serveTestRepo(t, remote, true)
+	local := newTestRepo(t)
+	first, second, third, other := pushTestHistory(t, local)
+
+	transport, err := openTransport(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		branch           string
+		oldHash, newHash string
+		force            bool
+		wantErr          string
+		wantRef          string
+	}{
+		{name: "new branch", branch: "master", newHash: first, wantRef: first},
+		{name: "fast-forward", branch: "master", oldHash: first, newHash: second, wantRef: second},
+		{name: "stale old value", branch: "master", oldHash: first, newHash: third,
+			wantErr: errStaleRef.Error(), wantRef: second},
+		{name: "non-fast-forward", branch: "master", oldHash: second, newHash: other,
+			wantErr: errNonFastForward.Error(), wantRef: second},
+		{name: "forced", branch: "master", oldHash: second, newHash: other, force: true, wantRef: other},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advertisement, err := transport.advertisedRefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = transport.pushBranch(test.branch, test.oldHash, test.newHash, test.force, advertisedTips(advertisement))
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatalf("pushBranch() = %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Fatalf("pushBranch() = %v, want an error with %q", err, test.wantErr)
+			}
+			if got := readRefIn(remote.dir, common.HEADS_DIR+"/"+test.branch); got != test.wantRef {
+				t.Errorf("remote %s = %s, want %s", test.branch, got, test.wantRef)
+			}
+		})
+	}
+
+	// A clone gets the history of the branch back, the blobs sent as deltas included
+	clone := testRepo{dir: filepath.Join(t.TempDir(), "clone", common.VCS_DIR_NAME)}
+	clone.workTree = filepath.Dir(clone.dir)
+	runCommand(t, common.CLONE, url, clone.workTree)
+	if got := headCommit(); got != other {
+		t.Fatalf("clone HEAD = %s, want %s", got, other)
+	}
+	if err := checkConnectivity(other); err != nil {
+		t.Errorf("clone is missing history: %v", err)
+	}
+	if got := clone.readWorkTreeFile(t, "big"); got != numberedLines(1, 500) {
+		t.Errorf("clone checked out big with %d bytes, want %d", len(got), len(numberedLines(1, 500)))
+	}
+}
+
+// streamHeaders returns the fields of the header of every object in a stream
+func streamHeaders(t *testing.T, stream []byte) [][]string {
+	t.Helper()
+	var headers [][]string
+	reader := bufio.NewReader(bytes.NewReader(stream))
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == objectStreamEnd {
+			return headers
+		}
+		fields := strings.Fields(line)
+		length, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			t.Fatalf("malformed header %q", line)
+		}
+		if _, err := reader.Discard(length); err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, fields)
+	}
+}
+
+// TestHTTPPushRefusesIncompleteHistory claims the remote has the parent of what is pushed when it has nothing
+func TestHTTPPushRefusesIncompleteHistory(t *testing.T) {
+	remote := newBareTestRepo(t)
+	url := serveTestRepo(t, remote, true)
+	local := newTestRepo(t)
+	_, second, third, _ := pushTestHistory(t, local)
+
+	transport, err := openTransport(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = transport.pushBranch(common.DEFAULT_BRANCH, "", third, false, []string{second})
+	if err == nil || !strings.Contains(err.Error(), "is missing") {
+		t.Fatalf("pushBranch() = %v, want a missing object", err)
+	}
+	if got := readRefIn(remote.dir, common.HEADS_DIR+"/"+common.DEFAULT_BRANCH); got != "" {
+		t.Errorf("remote branch moved to %s", got)
+	}
+}
+
+func TestObjectStreamRoundTrip(t *testing.T) {
+	source := newTestRepo(t)
+	first := source.commitFiles(t, "first", map[string]string{"big": numberedLines(1, 400), "small": "s\n"})
+	second := source.commitFiles(t, "second", map[string]string{"big": numberedLines(1, 400) + "changed\n"})
+
+	tests := []struct {
+		name       string
+		haves      []string
+		wantDeltas int
+	}{
+		{name: "everything", haves: nil, wantDeltas: 1},
+		{name: "on top of the first commit", haves: []string{first}, wantDeltas: 1},
+		{name: "already there", haves: []string{second}, wantDeltas: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			restore := source.use()
+			hashes, known := missingObjects([]string{second}, test.haves)
+			var stream bytes.Buffer
+			if err := writeObjectStream(&stream, hashes, known); err != nil {
+				t.Fatal(err)
+			}
+			restore()
+
+			deltas := 0
+			for _, header := range streamHeaders(t, stream.Bytes()) {
+				if header[2] != noHash {
+					deltas++
+				}
+			}
+			if deltas != test.wantDeltas {
+				t.Errorf("the stream has %d deltas, want %d", deltas, test.wantDeltas)
+			}
+
+			// The receiver already has what the haves reach, as it would in a real exchange
+			receiver := newBareTestRepo(t)
+			restore = receiver.use()
+			defer restore()
+			if len(test.haves) > 0 {
+				copyObjects(source.dir, receiver.dir)
+			}
+			count, err := readObjectStream(bufio.NewReader(&stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(hashes) {
+				t.Errorf("read %d objects, want %d", count, len(hashes))
+			}
+			if err := checkConnectivity(second); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestHTTPServerRefusesRequests sends requests a server must turn down without running out of memory or moving
+// a branch
+func TestHTTPServerRefusesRequests(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	update := pushUpdate + " " + common.DEFAULT_BRANCH + " " + noHash + " " + hash + " true\n"
+	tests := []struct {
+		name       string
+		allowPush  bool
+		path       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "push without --allow-push", path: pushPath, body: update + objectStreamEnd + "\n",
+			wantStatus: http.StatusForbidden, wantError: pushDisabled},
+		{name: "object claiming a huge length", allowPush: true, path: pushPath,
+			body:       update + hash + " blob - 100000000000000\nxx",
+			wantStatus: http.StatusConflict, wantError: errObjectTooLarge.Error()},
+		{name: "delta claiming a huge object", allowPush: true, path: pushPath,
+			body:       update + hash + " blob " + hash + " " + strconv.Itoa(len(hugeDelta)) + "\n" + string(hugeDelta),
+			wantStatus: http.StatusConflict, wantError: errObjectTooLarge.Error()},
+		{name: "fetch request over the limit", path: fetchPath,
+			body:       strings.Repeat(fetchHave+" "+hash+"\n", maxFetchRequestSize/len(hash)),
+			wantStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remote := newBareTestRepo(t)
+			url := serveTestRepo(t, remote, test.allowPush)
+			response, err := http.Post(url+test.path, protocolContentType, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			message, _ := io.ReadAll(response.Body)
+			if response.StatusCode != test.wantStatus || !strings.Contains(string(message), test.wantError) {
+				t.Errorf("the server replied %s %q, want %d with %q", response.Status, message, test.wantStatus, test.wantError)
+			}
+			if got := readRefIn(remote.dir, common.HEADS_DIR+"/"+common.DEFAULT_BRANCH); got != "" {
+				t.Errorf("remote master moved to %s", got)
+			}
+		})
+	}
+}
+
+// hugeDelta is a compressed delta that claims to rebuild an object of a terabyte
+var hugeDelta = func() []byte {
+	var delta bytes.Buffer
+	putUvarint(&delta, 0)
+	putUvarint(&delta, 1<<40)
+	return deflate(delta.Bytes())
+}()
+
+func TestReadRefAdvertisement(t *testing.T) {
+	hash := strings.Repeat("b", 64)
+	tests := []struct {
+		name         string
+		text         string
+		wantErr      error
+		wantHashName string
+	}{
+		{name: "complete", text: "HEAD master\nhash blake3\n" + hash + " master\nend\n", wantHashName: blake3HashName},
+		{name: "empty repository", text: "HEAD master\nhash sha256\nend\n", wantHashName: sha256HashName},
+		{name: "no hash line", text: "HEAD master\n" + hash + " master\nend\n", wantErr: errNoHashAdvertised},
+		{name: "bad branch", text: "hash sha256\n" + hash + " ../x\nend\n", wantErr: errMalformedRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advertisement, err := readRefAdvertisement(bufio.NewReader(strings.NewReader(test.text)))
+			if err != test.wantErr {
+				t.Fatalf("readRefAdvertisement() = %v, want %v", err, test.wantErr)
+			}
+			if advertisement.hashName != test.wantHashName {
+				t.Errorf("hash = %q, want %q", advertisement.hashName, test.wantHashName)
+			}
+		})
+	}
+}
+
+// TestReadObjectStreamTypes checks an object is stored with the type it was sent with, and only when that type
+// names it
+func TestReadObjectStreamTypes(t *testing.T) {
+	// A blob that parses as a commit, which guessing its type would have stored as one
+	looksLikeCommit := []byte("tree " + strings.Repeat("c", 64) + "\nauthor a 0 +0000\ncommitter a 0 +0000\n\nmessage\n")
+	blobHash := hashObject(blobType, looksLikeCommit)
+	stream := func(kind string) string {
+		payload := deflate(looksLikeCommit)
+		return blobHash + " " + kind + " " + noHash + " " + strconv.Itoa(len(payload)) + "\n" + string(payload) +
+			objectStreamEnd + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		wantErr string
+	}{
+		{name: "its type", kind: blobType},
+		{name: "no type", kind: noHash, wantErr: errMalformedRequest.Error()},
+		{name: "unknown type", kind: "tag", wantErr: errMalformedRequest.Error()},
+		{name: "another type", kind: commitType, wantErr: "does not match"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver := newBareTestRepo(t)
+			restore := receiver.use()
+			defer restore()
+
+			_, err := readObjectStream(bufio.NewReader(strings.NewReader(stream(test.kind))))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("readObjectStream() = %v, want an error with %q", err, test.wantErr)
+				}
+				if hasObject(blobHash) {
+					t.Error("the refused object was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind, _, err := readObjectWithType(blobHash); err != nil || kind != blobType {
+				t.Errorf("stored as %q, %v, want a blob", kind, err)
+			}
+		})
+	}
+}
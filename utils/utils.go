@@ -1,28 +1,21 @@
 package utils
 
 import (
-	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"version_control_go/common"
 )
 
 const whoAreYou = "Please, tell me who you are."
 const usernameIs = "The username is %s\n"
+const settingIs = "The setting %s is %s\n"
+const hashIsFixed = "core.hash is chosen when the repository is created, use 'init --hash <algorithm>' before storing anything."
 
 const addFileToIndex = "Add a file to the index."
 const trackedFiles = "Tracked files:"
 const fileIsTracked = "The file '%s' is tracked.\n"
 const canNotFindFile = "Can't find '%s'.\n"
 
-func CreateVcsDir() {
-	err := os.MkdirAll(common.VCS_DIR, os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
 func getConsoleInput() []string {
 	// config := flag.String("name", "", Commands["config"])
 	// add := flag.String("add", "", Commands["add"])
@@ -30,16 +23,21 @@ func getConsoleInput() []string {
 	// commit := flag.String("commit", "", Commands["commit"])
 	// checkout := flag.String("checkout", "", Commands["checkout"])
 
-	if len(os.Args) == 1 {
-		return []string{os.Args[0], common.HELP}
+	args := parseGlobalOptions(os.Args)
+	if len(args) == 1 {
+		return []string{args[0], common.HELP}
 	}
-	return os.Args
+	return args
 }
 
 func Interaction() {
 	consoleArgs := getConsoleInput()
 	command := consoleArgs[1]
 	if description, exists := common.Commands[command]; exists {
+		// Every command except help, init and clone works on an existing repository
+		if command != common.HELP && command != common.INIT && command != common.CLONE {
+			openRepository()
+		}
 		CommandSwitchCases(command, description, consoleArgs)
 	} else {
 		fmt.Printf(common.IS_NOT_COMMAND, command)
@@ -48,98 +46,97 @@ func Interaction() {
 
 func CommandSwitchCases(command string, description string, consoleArgs []string) {
 	switch command {
+	case common.INIT:
+		initCase(consoleArgs)
+	case common.CLONE:
+		cloneCase(consoleArgs)
 	case common.CONFIG:
 		configCase(consoleArgs)
 	case common.ADD:
 		addCase(consoleArgs)
 	case common.LOG:
-		fmt.Println(description)
+		logCase(consoleArgs)
 	case common.COMMIT:
-		fmt.Println(description)
+		commitCase(consoleArgs)
 	case common.CHECKOUT:
-		fmt.Println(description)
+		checkoutCase(consoleArgs)
+	case common.REFLOG:
+		reflogCase(consoleArgs)
+	case common.STASH:
+		stashCase(consoleArgs)
+	case common.CHERRY_PICK:
+		cherryPickCase(consoleArgs)
+	case common.REVERT:
+		revertCase(consoleArgs)
+	case common.REBASE:
+		rebaseCase(consoleArgs)
+	case common.BLAME:
+		blameCase(consoleArgs)
+	case common.BISECT:
+		bisectCase(consoleArgs)
+	case common.REMOTE:
+		remoteCase(consoleArgs)
+	case common.FETCH:
+		fetchCase(consoleArgs)
+	case common.PUSH:
+		pushCase(consoleArgs)
+	case common.PULL:
+		pullCase(consoleArgs)
+	case common.SERVE:
+		serveCase(consoleArgs)
+	case common.GC:
+		gcCase(consoleArgs)
+	case common.PRUNE:
+		pruneCase(consoleArgs)
+	case common.FSCK:
+		fsckCase(consoleArgs)
 	default:
 		fmt.Println(description)
 	}
 }
 
 func configCase(consoleArgs []string) {
-	configFilePath := common.VCS_DIR + "/" + common.CONFIG_FILE_NAME
 	if len(consoleArgs) < 3 {
-		file, err := os.OpenFile(configFilePath, os.O_RDONLY|os.O_CREATE, 0644)
-		if err != nil {
+		username := getConfig(common.USER_NAME_KEY)
+		if username == "" {
 			fmt.Println(whoAreYou)
+			return
 		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			fmt.Printf(usernameIs, scanner.Text())
+		fmt.Printf(usernameIs, username)
+	} else if len(consoleArgs) > 3 {
+		// "config <key> <value>" sets any other setting, such as core.compression
+		if consoleArgs[2] == common.HASH_KEY {
+			fmt.Println(hashIsFixed)
 			return
 		}
-		fmt.Println(whoAreYou)
+		setConfig(consoleArgs[2], consoleArgs[3])
+		fmt.Printf(settingIs, consoleArgs[2], consoleArgs[3])
 	} else {
 		// set their name or output an already existing name
-		configFile := getOpenFileToWriteOnlyOrCreate(configFilePath)
-		defer configFile.Close()
-		if err := os.WriteFile(configFilePath, []byte(consoleArgs[2]), 0644); err != nil {
-			log.Fatal(err) // exit the program if we have an unexpected error
-		}
+		setConfig(common.USER_NAME_KEY, consoleArgs[2])
 		fmt.Printf(usernameIs, consoleArgs[2])
 	}
 }
 
 func addCase(consoleArgs []string) {
-	indexFilePath := common.VCS_DIR + "/" + common.INDEX_FILE_NAME
-	if len(consoleArgs) < 3 {
-		file, err := os.OpenFile(indexFilePath, os.O_RDONLY|os.O_CREATE, 0644)
-		if err != nil {
-			fmt.Println(addFileToIndex)
-		}
-		defer file.Close()
-
-		numFilesTracked := 0
+	requireWorkTree()
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			if numFilesTracked == 0 {
-				fmt.Println(trackedFiles)
-			}
-			fmt.Println(scanner.Text())
-			numFilesTracked++
-		}
-		if numFilesTracked < 1 {
+	if len(consoleArgs) < 3 {
+		entries := readIndex()
+		if len(entries) < 1 {
 			fmt.Println(addFileToIndex)
-		}
-	} else {
-		// Make sure args file exist
-		_, e := os.Open(consoleArgs[2])
-		if e != nil {
-			fmt.Printf(canNotFindFile, consoleArgs[2])
 			return
 		}
 
-		// Append, Write or Create index.html
-		indexFile, err := os.OpenFile(indexFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			log.Fatal(err)
+		fmt.Println(trackedFiles)
+		for _, entry := range entries {
+			fmt.Println(entry.path)
 		}
-		defer indexFile.Close()
-
-		b, error := fmt.Fprintln(indexFile, consoleArgs[2])
-		if error != nil {
-			log.Fatal(error, b)
-		}
-		fmt.Printf(fileIsTracked, consoleArgs[2])
-	}
-}
-
-func getOpenFileToWriteOnlyOrCreate(path string) *os.File {
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		log.Fatal(err)
+	} else if consoleArgs[2] == common.PATCH_FLAG {
+		addPatch(consoleArgs[3:])
+	} else {
+		addPaths(consoleArgs[2:])
 	}
-	return file
 }
 
 // func readInput() string {